@@ -0,0 +1,26 @@
+package antispam
+
+import "context"
+
+// FakeCaptcha accepts single known response, so forms can be submitted
+// locally and in tests without CAPTCHA provider.
+type FakeCaptcha struct {
+	accept string
+}
+
+const FakeCaptchaField = "captcha"
+
+func NewFakeCaptcha(accept string) *FakeCaptcha {
+	return &FakeCaptcha{accept}
+}
+
+func (f *FakeCaptcha) Verify(response string, remoteIP string, ctx context.Context) error {
+	if response != f.accept {
+		return ErrCaptcha
+	}
+	return nil
+}
+
+func (f *FakeCaptcha) Widget() Widget {
+	return Widget{Field: FakeCaptchaField}
+}
@@ -0,0 +1,152 @@
+// Package antispam tells people filling subscription form from bots.
+package antispam
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+)
+
+const (
+	// HoneypotField is hidden from people, so only bots fill it in.
+	HoneypotField = "website"
+	// IssuedField carries time form was rendered at, signed together with
+	// session it was rendered for.
+	IssuedField = "issued"
+	// CaptchaHeader carries CAPTCHA response of clients which post JSON
+	// rather than form.
+	CaptchaHeader = "X-Captcha-Response"
+
+	// MinSubmitDelay is the fastest person can fill form in.
+	MinSubmitDelay = 3 * time.Second
+	// FormLifetime is how long rendered form is accepted.
+	FormLifetime = time.Hour
+)
+
+var (
+	ErrBotSubmission = apperrors.New(apperrors.Validation, "submission looks automated, please fill the form again")
+	ErrCaptcha       = apperrors.New(apperrors.Validation, "CAPTCHA is not solved")
+	ErrUnverified    = apperrors.New(apperrors.Forbidden, "subscribe with the form, CAPTCHA or API key")
+)
+
+type (
+	// Guard checks form submissions with honeypot field, time to submit and
+	// optional CAPTCHA.
+	Guard struct {
+		secret  []byte
+		captcha CaptchaVerifier
+	}
+
+	// CaptchaVerifier checks CAPTCHA response submitted with form.
+	CaptchaVerifier interface {
+		Verify(response string, remoteIP string, ctx context.Context) error
+		Widget() Widget
+	}
+
+	// Widget is what form needs to render CAPTCHA.
+	Widget struct {
+		ScriptUrl string
+		Class     string
+		SiteKey   string
+		Field     string
+	}
+
+	// Form is data of rendered form that Guard checks on submission.
+	Form struct {
+		Issued  string
+		Captcha *Widget
+	}
+)
+
+// NewGuard signs form timestamps with secret. captcha may be nil.
+func NewGuard(secret []byte, captcha CaptchaVerifier) *Guard {
+	return &Guard{secret, captcha}
+}
+
+// NewForm renders form for session, which is any value bound to client
+// that submission of the form carries too, such as CSRF token. Form
+// submitted within other session is rejected, so that its timestamp can not
+// be handed out to bots.
+func (g *Guard) NewForm(session string) Form {
+	form := Form{Issued: g.sign(time.Now(), session)}
+	if g.captcha != nil {
+		widget := g.captcha.Widget()
+		form.Captcha = &widget
+	}
+
+	return form
+}
+
+// Check rejects submission when honeypot is filled, when form was
+// submitted too fast or too late, with forged timestamp or timestamp of
+// other session, and when CAPTCHA is not solved.
+func (g *Guard) Check(form url.Values, session string, remoteIP string, ctx context.Context) error {
+	if form.Get(HoneypotField) != "" {
+		return ErrBotSubmission
+	}
+
+	issued, ok := g.verify(form.Get(IssuedField), session)
+	if !ok {
+		return ErrBotSubmission
+	}
+
+	age := time.Since(issued)
+	if age < MinSubmitDelay || age > FormLifetime {
+		return ErrBotSubmission
+	}
+
+	if g.captcha == nil {
+		return nil
+	}
+
+	return g.CheckCaptcha(form.Get(g.captcha.Widget().Field), remoteIP, ctx)
+}
+
+// CheckCaptcha rejects submission without solved CAPTCHA. It guards
+// clients which do not render form, so have neither honeypot nor
+// timestamp; when CAPTCHA is disabled nothing tells them from bots, so
+// every one is rejected.
+func (g *Guard) CheckCaptcha(response string, remoteIP string, ctx context.Context) error {
+	if g.captcha == nil {
+		return ErrUnverified
+	}
+
+	if response == "" {
+		return ErrCaptcha
+	}
+
+	return g.captcha.Verify(response, remoteIP, ctx)
+}
+
+// sign encodes t as `<unix milliseconds>.<hmac>`, hmac covers session too.
+func (g *Guard) sign(t time.Time, session string) string {
+	value := strconv.FormatInt(t.UnixMilli(), 10)
+	return value + "." + g.mac(value, session)
+}
+
+func (g *Guard) verify(signed string, session string) (time.Time, bool) {
+	value, mac, ok := strings.Cut(signed, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(g.mac(value, session))) {
+		return time.Time{}, false
+	}
+
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(millis), true
+}
+
+func (g *Guard) mac(value string, session string) string {
+	h := hmac.New(sha256.New, g.secret)
+	h.Write([]byte("form-issued:" + value + ":" + session))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package antispam
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// session is what forms of tests are rendered for.
+const session = "csrf token"
+
+func TestCheck(t *testing.T) {
+	guard := NewGuard([]byte("secret"), NewFakeCaptcha("solved"))
+	other := NewGuard([]byte("other secret"), nil)
+
+	tests := []struct {
+		name string
+		form url.Values
+		want error
+	}{
+		{
+			name: "filled by person",
+			form: url.Values{IssuedField: {guard.sign(time.Now().Add(-time.Minute), session)}, FakeCaptchaField: {"solved"}},
+		},
+		{
+			name: "honeypot filled",
+			form: url.Values{IssuedField: {guard.sign(time.Now().Add(-time.Minute), session)}, FakeCaptchaField: {"solved"}, HoneypotField: {"http://spam.example.com"}},
+			want: ErrBotSubmission,
+		},
+		{
+			name: "submitted too fast",
+			form: url.Values{IssuedField: {guard.sign(time.Now(), session)}, FakeCaptchaField: {"solved"}},
+			want: ErrBotSubmission,
+		},
+		{
+			name: "submitted too late",
+			form: url.Values{IssuedField: {guard.sign(time.Now().Add(-FormLifetime-time.Minute), session)}, FakeCaptchaField: {"solved"}},
+			want: ErrBotSubmission,
+		},
+		{
+			name: "timestamp forged",
+			form: url.Values{IssuedField: {other.sign(time.Now().Add(-time.Minute), session)}, FakeCaptchaField: {"solved"}},
+			want: ErrBotSubmission,
+		},
+		{
+			name: "rendered for other session",
+			form: url.Values{IssuedField: {guard.sign(time.Now().Add(-time.Minute), "other csrf token")}, FakeCaptchaField: {"solved"}},
+			want: ErrBotSubmission,
+		},
+		{
+			name: "CAPTCHA missing",
+			form: url.Values{IssuedField: {guard.sign(time.Now().Add(-time.Minute), session)}},
+			want: ErrCaptcha,
+		},
+		{
+			name: "CAPTCHA wrong",
+			form: url.Values{IssuedField: {guard.sign(time.Now().Add(-time.Minute), session)}, FakeCaptchaField: {"guessed"}},
+			want: ErrCaptcha,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := guard.Check(test.form, session, "127.0.0.1", context.Background()); !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckCaptcha(t *testing.T) {
+	guard := NewGuard([]byte("secret"), NewFakeCaptcha("solved"))
+
+	tests := []struct {
+		response string
+		want     error
+	}{
+		{"solved", nil},
+		{"", ErrCaptcha},
+		{"guessed", ErrCaptcha},
+	}
+
+	for _, test := range tests {
+		if err := guard.CheckCaptcha(test.response, "127.0.0.1", context.Background()); !errors.Is(err, test.want) {
+			t.Errorf("response %q: got %v, want %v", test.response, err, test.want)
+		}
+	}
+
+	// without CAPTCHA nothing tells clients without form from bots
+	disabled := NewGuard([]byte("secret"), nil)
+	if err := disabled.CheckCaptcha("", "127.0.0.1", context.Background()); !errors.Is(err, ErrUnverified) {
+		t.Errorf("disabled CAPTCHA: got %v, want %v", err, ErrUnverified)
+	}
+}
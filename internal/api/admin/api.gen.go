@@ -0,0 +1,521 @@
+// Package apiadmin provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.4.1 DO NOT EDIT.
+package apiadmin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/oapi-codegen/runtime"
+)
+
+const (
+	AdminTokenScopes = "adminToken.Scopes"
+)
+
+// Defines values for Frequency.
+const (
+	Daily  Frequency = "daily"
+	Hourly Frequency = "hourly"
+)
+
+// AuditPage defines model for AuditPage.
+type AuditPage struct {
+	Items    []AuditRecord `json:"items"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+	Total    int           `json:"total"`
+}
+
+// AuditRecord defines model for AuditRecord.
+type AuditRecord struct {
+	Action         string    `json:"action"`
+	Actor          string    `json:"actor"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Details        *string   `json:"details,omitempty"`
+	Id             int       `json:"id"`
+	SubscriptionId *int      `json:"subscriptionId,omitempty"`
+}
+
+// Delivery defines model for Delivery.
+type Delivery struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Error     *string   `json:"error,omitempty"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status"`
+}
+
+// FieldError defines model for FieldError.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Frequency defines model for Frequency.
+type Frequency string
+
+// Problem Error response (RFC 7807)
+type Problem struct {
+	Detail   *string       `json:"detail,omitempty"`
+	Errors   *[]FieldError `json:"errors,omitempty"`
+	Instance *string       `json:"instance,omitempty"`
+	Status   int           `json:"status"`
+	Title    string        `json:"title"`
+	Type     string        `json:"type"`
+}
+
+// Subscription defines model for Subscription.
+type Subscription struct {
+	City      string    `json:"city"`
+	Confirmed bool      `json:"confirmed"`
+	CreatedAt time.Time `json:"createdAt"`
+	Email     string    `json:"email"`
+	Frequency string    `json:"frequency"`
+	Id        int       `json:"id"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SubscriptionDetails defines model for SubscriptionDetails.
+type SubscriptionDetails struct {
+	Deliveries   []Delivery   `json:"deliveries"`
+	Subscription Subscription `json:"subscription"`
+	Tokens       []Token      `json:"tokens"`
+}
+
+// SubscriptionPage defines model for SubscriptionPage.
+type SubscriptionPage struct {
+	Items    []Subscription `json:"items"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+	Total    int            `json:"total"`
+}
+
+// Token Token metadata, raw tokens are never stored
+type Token struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	Expires    time.Time `json:"expires"`
+	HashPrefix string    `json:"hashPrefix"`
+}
+
+// Page defines model for Page.
+type Page = int
+
+// PageSize defines model for PageSize.
+type PageSize = int
+
+// SubscriptionId defines model for SubscriptionId.
+type SubscriptionId = int
+
+// ListAuditRecordsParams defines parameters for ListAuditRecords.
+type ListAuditRecordsParams struct {
+	Page     *Page     `form:"page,omitempty" json:"page,omitempty"`
+	PageSize *PageSize `form:"pageSize,omitempty" json:"pageSize,omitempty"`
+}
+
+// ListSubscriptionsParams defines parameters for ListSubscriptions.
+type ListSubscriptionsParams struct {
+	// City City, case insensitive
+	City      *string    `form:"city,omitempty" json:"city,omitempty"`
+	Frequency *Frequency `form:"frequency,omitempty" json:"frequency,omitempty"`
+	Confirmed *bool      `form:"confirmed,omitempty" json:"confirmed,omitempty"`
+
+	// CreatedFrom Inclusive lower bound of creation time
+	CreatedFrom *time.Time `form:"createdFrom,omitempty" json:"createdFrom,omitempty"`
+
+	// CreatedTo Exclusive upper bound of creation time
+	CreatedTo *time.Time `form:"createdTo,omitempty" json:"createdTo,omitempty"`
+	Page      *Page      `form:"page,omitempty" json:"page,omitempty"`
+	PageSize  *PageSize  `form:"pageSize,omitempty" json:"pageSize,omitempty"`
+}
+
+// ServerInterface represents all server handlers.
+type ServerInterface interface {
+	// List audit records of admin actions
+	// (GET /audit)
+	ListAuditRecords(c *gin.Context, params ListAuditRecordsParams)
+	// Search subscriptions
+	// (GET /subscriptions)
+	ListSubscriptions(c *gin.Context, params ListSubscriptionsParams)
+	// Delete subscription
+	// (DELETE /subscriptions/{id})
+	DeleteSubscription(c *gin.Context, id SubscriptionId)
+	// Get subscription with its tokens and delivery history
+	// (GET /subscriptions/{id})
+	GetSubscriptionDetails(c *gin.Context, id SubscriptionId)
+	// Confirm subscription manually
+	// (POST /subscriptions/{id}/confirm)
+	ConfirmSubscription(c *gin.Context, id SubscriptionId)
+	// Stop sending letters to subscription
+	// (POST /subscriptions/{id}/deactivate)
+	DeactivateSubscription(c *gin.Context, id SubscriptionId)
+}
+
+// ServerInterfaceWrapper converts contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler            ServerInterface
+	HandlerMiddlewares []MiddlewareFunc
+	ErrorHandler       func(*gin.Context, error, int)
+}
+
+type MiddlewareFunc func(c *gin.Context)
+
+// ListAuditRecords operation middleware
+func (siw *ServerInterfaceWrapper) ListAuditRecords(c *gin.Context) {
+
+	var err error
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListAuditRecordsParams
+
+	// ------------- Optional query parameter "page" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "page", c.Request.URL.Query(), &params.Page)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter page: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "pageSize" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "pageSize", c.Request.URL.Query(), &params.PageSize)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter pageSize: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListAuditRecords(c, params)
+}
+
+// ListSubscriptions operation middleware
+func (siw *ServerInterfaceWrapper) ListSubscriptions(c *gin.Context) {
+
+	var err error
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListSubscriptionsParams
+
+	// ------------- Optional query parameter "city" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "city", c.Request.URL.Query(), &params.City)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter city: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "frequency" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "frequency", c.Request.URL.Query(), &params.Frequency)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter frequency: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "confirmed" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "confirmed", c.Request.URL.Query(), &params.Confirmed)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter confirmed: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "createdFrom" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "createdFrom", c.Request.URL.Query(), &params.CreatedFrom)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter createdFrom: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "createdTo" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "createdTo", c.Request.URL.Query(), &params.CreatedTo)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter createdTo: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "page" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "page", c.Request.URL.Query(), &params.Page)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter page: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "pageSize" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "pageSize", c.Request.URL.Query(), &params.PageSize)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter pageSize: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListSubscriptions(c, params)
+}
+
+// DeleteSubscription operation middleware
+func (siw *ServerInterfaceWrapper) DeleteSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteSubscription(c, id)
+}
+
+// GetSubscriptionDetails operation middleware
+func (siw *ServerInterfaceWrapper) GetSubscriptionDetails(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetSubscriptionDetails(c, id)
+}
+
+// ConfirmSubscription operation middleware
+func (siw *ServerInterfaceWrapper) ConfirmSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ConfirmSubscription(c, id)
+}
+
+// DeactivateSubscription operation middleware
+func (siw *ServerInterfaceWrapper) DeactivateSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeactivateSubscription(c, id)
+}
+
+// GinServerOptions provides options for the Gin server.
+type GinServerOptions struct {
+	BaseURL      string
+	Middlewares  []MiddlewareFunc
+	ErrorHandler func(*gin.Context, error, int)
+}
+
+// RegisterHandlers creates http.Handler with routing matching OpenAPI spec.
+func RegisterHandlers(router gin.IRouter, si ServerInterface) {
+	RegisterHandlersWithOptions(router, si, GinServerOptions{})
+}
+
+// RegisterHandlersWithOptions creates http.Handler with additional options
+func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options GinServerOptions) {
+	errorHandler := options.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(c *gin.Context, err error, statusCode int) {
+			c.JSON(statusCode, gin.H{"msg": err.Error()})
+		}
+	}
+
+	wrapper := ServerInterfaceWrapper{
+		Handler:            si,
+		HandlerMiddlewares: options.Middlewares,
+		ErrorHandler:       errorHandler,
+	}
+
+	router.GET(options.BaseURL+"/audit", wrapper.ListAuditRecords)
+	router.GET(options.BaseURL+"/subscriptions", wrapper.ListSubscriptions)
+	router.DELETE(options.BaseURL+"/subscriptions/:id", wrapper.DeleteSubscription)
+	router.GET(options.BaseURL+"/subscriptions/:id", wrapper.GetSubscriptionDetails)
+	router.POST(options.BaseURL+"/subscriptions/:id/confirm", wrapper.ConfirmSubscription)
+	router.POST(options.BaseURL+"/subscriptions/:id/deactivate", wrapper.DeactivateSubscription)
+}
+
+// Base64 encoded, gzipped, json marshaled Swagger object
+var swaggerSpec = []string{
+
+	"H4sIAAAAAAAC/9RYS4/bNhD+KwTbQ4uqtvMoUvi2yGaDAAUaxAF6WPjAFccWE4lUyJGzbqD/XpDUg5K4",
+	"ttZJA+RkWSRnvvnmSX2hqSpKJUGioesvtGSaFYCg3b+3bA/2V0i6pp8q0EeaUMkKoGta2rWEmjSDgtlN",
+	"HHasypGunyS0EFIUVeGe8Vja/UIi7EHTuk6c3I3496Rstx6V/8cqoQW79wqerlZn1W2qO5NqUaJQ8g3v",
+	"lJYMs16n4DShGj5VQgOna9QVhNpPqqjtSVMqacDzptVdDoV9TJVEkGgfWVnmImUWxbL0O377YJS0a72i",
+	"nzXs6Jr+tOwds/SrZtnKdRo5dEbRNX2ltdKkRUFHVp9A8jgEA6ERGKP1lkHHylXFBbYhVWpVgkbhCRMI",
+	"xfDhFAgn6B2kSnNad+5gWrOj/V82KsZuSvq4iq6iQpbHluowMG4bjEmbAUGwegnbDpK6+wApWtkh5In1",
+	"LG191JwzqIXc23MsRaWjK6kGhsCvnEN3ShcM6ZpyhvA7isKhGR/hgEzkJipO8DgpZpI759jhtIWdtJaF",
+	"aGPsXEMuDrYCTKi5wEqwmRC18aOQPLpgkGEV42VkmxPQbT9n1Y2AnL9q0Qzt2tm1KJYCjBkG8ANgvIj+",
+	"QBSCPQAydcyCtPXrlmaq0rmttpyJ/Bic60EEBexUmSG/vLt5SV78uXrxK01GFvpgi5roPDQ/3QMeI9ku",
+	"pEEmU5jn2DDdBebxU/7FOf7daiumUxXzwrgSj0Jc4DGe4UruhC4gjJM7pXJg8sICAMVDLtmFgTK7OFQl",
+	"fxyEWK3woBLPQ4gkZCA0N9R7ju7rvuSNw9OVHAHz47CrUpEoNCMXz++htmt8BDkfxnu7fYphRO0AUKcj",
+	"Ce0+x923aNUTW3+AXu0JnpQ+95oUgIwzZAnR7DPxtBKmgUg4gCYGlXbh+vVt7L4UGsz8Axkz2VsNO3F/",
+	"vnYFe3tNp/uZDXJIKy3wuLHObUYXXgjZEea87soUMA26R5khln5cFHKnptz+XYJmqDS5evuG7JQmBphO",
+	"MyH3hElOCibZ3v4Jo9osyCubjiTNmNwDEYYwO2UBX3RleU3/AYYZaHKjNKTMILmyiK0emtADaOMBPFms",
+	"FivLoipBslLQNX22WC2euZjBzNm6dOLt0x7cj3Kom8GI/iUMBmOej7r+NnUbT5R+y9LlW53M2ueCuN6O",
+	"7h1PV6tvNun303pkzLfvidp5woluDK4T+nz15CHBHdLgGmMLZ1EwfWz4Gwp0Gpy7/BxpKUW2t2T6wKNb",
+	"K2I5iIqT/tkMdk4cNDTypcBjQlJmgAhpQBqB4gA0iV5Zm+7VcztJwPhVN2x38xzTz3QPCg375gRRN0LU",
+	"ydjiNzLNKyMOQHL1GTS5U5Xk1guuMAglSVN8okp98bjRqhionTcXTAbN+xZKVZYXQnmvLgLyQ6XppGGf",
+	"yNZhnrhsXT0iW78quzeuoI8gzEjn5RfB62ZgA4RpWl+795vhuDOi+/m044QHiJfNH2+i3f/8Uko8cjKe",
+	"1EaMJPFq9howNud+p0hr1Z35AkTaLw7fl9jXgANWyWeBGRFounlNctIMwkeSCYNKH6PUP66Bj74z1tt4",
+	"NC+b8jz+3nqBgoSWykSi46XXcDorZuT+eHz/jk5sTBg6smCyYnl+nF05lhzs7HBgvnT8T3Rfd0p+ZMY3",
+	"qEpiQHI7aeeAlgOC6lyBCm8GjtXwTnC7tawZ0IeW80rndE2X/nS97eQ9eCXoqDZ9g29P1/8NAN4lJIfA",
+	"GAAA",
+}
+
+// GetSwagger returns the content of the embedded swagger specification file
+// or error if failed to decode
+func decodeSpec() ([]byte, error) {
+	zipped, err := base64.StdEncoding.DecodeString(strings.Join(swaggerSpec, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding spec: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var rawSpec = decodeSpecCached()
+
+// a naive cached of a decoded swagger spec
+func decodeSpecCached() func() ([]byte, error) {
+	data, err := decodeSpec()
+	return func() ([]byte, error) {
+		return data, err
+	}
+}
+
+// Constructs a synthetic filesystem for resolving external references when loading openapi specifications.
+func PathToRawSpec(pathToFile string) map[string]func() ([]byte, error) {
+	res := make(map[string]func() ([]byte, error))
+	if len(pathToFile) > 0 {
+		res[pathToFile] = rawSpec
+	}
+
+	return res
+}
+
+// GetSwagger returns the Swagger specification corresponding to the generated code
+// in this file. The external references of Swagger specification are resolved.
+// The logic of resolving external references is tightly connected to "import-mapping" feature.
+// Externally referenced files must be embedded in the corresponding golang packages.
+// Urls can be supported but this task was out of the scope.
+func GetSwagger() (swagger *openapi3.T, err error) {
+	resolvePath := PathToRawSpec("")
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(loader *openapi3.Loader, url *url.URL) ([]byte, error) {
+		pathToFile := url.String()
+		pathToFile = path.Clean(pathToFile)
+		getSpec, ok := resolvePath[pathToFile]
+		if !ok {
+			err1 := fmt.Errorf("path not found: %s", pathToFile)
+			return nil, err1
+		}
+		return getSpec()
+	}
+	var specData []byte
+	specData, err = rawSpec()
+	if err != nil {
+		return
+	}
+	swagger, err = loader.LoadFromData(specData)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,4 @@
+// Package apiadmin contains HTTP server interface generated from api/openapi.admin.yaml.
+package apiadmin
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen --config ../../../api/oapi-codegen.admin.yaml ../../../api/openapi.admin.yaml
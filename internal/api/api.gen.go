@@ -0,0 +1,381 @@
+// Package api provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.4.1 DO NOT EDIT.
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/oapi-codegen/runtime"
+)
+
+const (
+	ApiKeyScopes = "apiKey.Scopes"
+)
+
+// Defines values for Frequency.
+const (
+	Daily  Frequency = "daily"
+	Hourly Frequency = "hourly"
+)
+
+// FieldError defines model for FieldError.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Frequency Frequency of updates
+type Frequency string
+
+// Problem Error response (RFC 7807)
+type Problem struct {
+	Detail *string `json:"detail,omitempty"`
+
+	// Errors Rejected input fields
+	Errors   *[]FieldError `json:"errors,omitempty"`
+	Instance *string       `json:"instance,omitempty"`
+	Status   int           `json:"status"`
+	Title    string        `json:"title"`
+	Type     string        `json:"type"`
+}
+
+// Result defines model for Result.
+type Result struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// SubscriptionForm Subscription form. Besides subscription fields it carries bot protection fields, CAPTCHA response is sent in field of configured provider.
+type SubscriptionForm struct {
+	City string `json:"city"`
+
+	// CsrfToken Token bound to `csrf` cookie, can be sent in `X-CSRF-Token` header instead
+	CsrfToken *string `json:"csrf_token"`
+	Email     string  `json:"email"`
+
+	// Frequency Frequency of updates
+	Frequency Frequency `json:"frequency"`
+
+	// Issued Signed time form was rendered at
+	Issued string `json:"issued"`
+
+	// Website Honeypot, should be empty
+	Website *string `json:"website"`
+}
+
+// SubscriptionRequest defines model for SubscriptionRequest.
+type SubscriptionRequest struct {
+	// City City for weather updates, must be known to weather provider
+	City string `json:"city"`
+
+	// Email Email address (RFC 5322, without display name)
+	Email string `json:"email"`
+
+	// Frequency Frequency of updates
+	Frequency Frequency `json:"frequency"`
+}
+
+// Weather defines model for Weather.
+type Weather struct {
+	// Description Weather description
+	Description string `json:"description"`
+
+	// Humidity Current humidity percentage
+	Humidity float32 `json:"humidity"`
+
+	// Temperature Current temperature
+	Temperature float32 `json:"temperature"`
+}
+
+// Token defines model for Token.
+type Token = string
+
+// ErrorOutcome Error response (RFC 7807)
+type ErrorOutcome = Problem
+
+// Outcome defines model for Outcome.
+type Outcome = Result
+
+// GetWeatherParams defines parameters for GetWeather.
+type GetWeatherParams struct {
+	// City City name for weather forecast
+	City string `form:"city" json:"city"`
+}
+
+// SubscribeJSONRequestBody defines body for Subscribe for application/json ContentType.
+type SubscribeJSONRequestBody = SubscriptionRequest
+
+// SubscribeFormdataRequestBody defines body for Subscribe for application/x-www-form-urlencoded ContentType.
+type SubscribeFormdataRequestBody = SubscriptionForm
+
+// ServerInterface represents all server handlers.
+type ServerInterface interface {
+	// Confirm email subscription
+	// (GET /confirm/{token})
+	ConfirmSubscription(c *gin.Context, token Token)
+	// Subscribe to weather updates
+	// (POST /subscribe)
+	Subscribe(c *gin.Context)
+	// Unsubscribe from weather updates
+	// (GET /unsubscribe/{token})
+	Unsubscribe(c *gin.Context, token Token)
+	// Get current weather for a city
+	// (GET /weather)
+	GetWeather(c *gin.Context, params GetWeatherParams)
+}
+
+// ServerInterfaceWrapper converts contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler            ServerInterface
+	HandlerMiddlewares []MiddlewareFunc
+	ErrorHandler       func(*gin.Context, error, int)
+}
+
+type MiddlewareFunc func(c *gin.Context)
+
+// ConfirmSubscription operation middleware
+func (siw *ServerInterfaceWrapper) ConfirmSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "token" -------------
+	var token Token
+
+	err = runtime.BindStyledParameterWithOptions("simple", "token", c.Param("token"), &token, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter token: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ConfirmSubscription(c, token)
+}
+
+// Subscribe operation middleware
+func (siw *ServerInterfaceWrapper) Subscribe(c *gin.Context) {
+
+	c.Set(ApiKeyScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.Subscribe(c)
+}
+
+// Unsubscribe operation middleware
+func (siw *ServerInterfaceWrapper) Unsubscribe(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "token" -------------
+	var token Token
+
+	err = runtime.BindStyledParameterWithOptions("simple", "token", c.Param("token"), &token, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter token: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.Unsubscribe(c, token)
+}
+
+// GetWeather operation middleware
+func (siw *ServerInterfaceWrapper) GetWeather(c *gin.Context) {
+
+	var err error
+
+	c.Set(ApiKeyScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetWeatherParams
+
+	// ------------- Required query parameter "city" -------------
+
+	if paramValue := c.Query("city"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandler(c, fmt.Errorf("Query argument city is required, but not found"), http.StatusBadRequest)
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "city", c.Request.URL.Query(), &params.City)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter city: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetWeather(c, params)
+}
+
+// GinServerOptions provides options for the Gin server.
+type GinServerOptions struct {
+	BaseURL      string
+	Middlewares  []MiddlewareFunc
+	ErrorHandler func(*gin.Context, error, int)
+}
+
+// RegisterHandlers creates http.Handler with routing matching OpenAPI spec.
+func RegisterHandlers(router gin.IRouter, si ServerInterface) {
+	RegisterHandlersWithOptions(router, si, GinServerOptions{})
+}
+
+// RegisterHandlersWithOptions creates http.Handler with additional options
+func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options GinServerOptions) {
+	errorHandler := options.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(c *gin.Context, err error, statusCode int) {
+			c.JSON(statusCode, gin.H{"msg": err.Error()})
+		}
+	}
+
+	wrapper := ServerInterfaceWrapper{
+		Handler:            si,
+		HandlerMiddlewares: options.Middlewares,
+		ErrorHandler:       errorHandler,
+	}
+
+	router.GET(options.BaseURL+"/confirm/:token", wrapper.ConfirmSubscription)
+	router.POST(options.BaseURL+"/subscribe", wrapper.Subscribe)
+	router.GET(options.BaseURL+"/unsubscribe/:token", wrapper.Unsubscribe)
+	router.GET(options.BaseURL+"/weather", wrapper.GetWeather)
+}
+
+// Base64 encoded, gzipped, json marshaled Swagger object
+var swaggerSpec = []string{
+
+	"H4sIAAAAAAAC/+RYW2/byhH+K4NtH3pQSnLsBKdHfXKM+iTtaWPYKXqAwKhW3JG0CbnL7M5aEQL992KW",
+	"d5OSL3Xbh76R3JnZuXxz43eR2rywBg15Mf8uCulkjoQuvn20X9Dwg0KfOl2QtkbMy8/g0RBoA5hLnYlE",
+	"aD4qJG1EIozMUcwFRf5EOPwatEMl5uQCJsKnG8wlC6ZdwYSenDZrsd/vmdgX1niMGvzJOes+BEotC2Rt",
+	"DaEhfpRFkelUsk6zwtllhvnvP3sb9W0v+K3DlZiL38xaO2flqZ9dlVyCbyX8RrMN5VmffUS/vi/OU36A",
+	"ldQZqim8dXbr0XlYI8G7j3/9BQq5xgQsbdBBmmlWIB5WKsOfbz78bSr2iXjYyqdZd40+ZPQSxvmQpojq",
+	"afY1dtVe/s9Fb6B3RA3UQIouqLhY6KXGTEUSfiucLdCRLvG24rMR3yQiR+/lGsf81gX4p0pEy3Cb1Ax2",
+	"+RlTYmGXzIAm3Q2TqzkCu4JQKEnoRSLQhJylb2xw2U4kQkmd7TrCW007Dj/mFvjd9eUF/PiHkx9/EMk9",
+	"NygkTuoxPyAL8UPh18jGoQJtikAQvcCKa8LcPxTLTkj2jUXSObnjd208SZPiqD6eJAXfOdKGcI2lIE3Z",
+	"OFf54aFQxtNaTHPVWECrZBvg6TBqRjQ/oEVFdxxRN2HZROPSupHodylgZV0+hbfotUIPvncUIweaIJXO",
+	"afSwtLFcEaYdggQuzq8+Xrw7bwGlfdMUIg0jOLVmpdfBoWIRd1qhmw7glmqKmZDLb7+gWdNGzE/fvElE",
+	"rk39/moE6Kl3q3/SsR61tMEoIAsLpl1Aau0XjQmk0sASG20Xv04ubq4vJ5FpARuUCh0w7FByKpuQZXKZ",
+	"Yd2/hkmRV+ly34IB5aqb+UdToiHkDPA+oBqJqV4bVEA6xxhS2EoPDo1CdrgkMaLBFpdeEw6FvbMGd4Wl",
+	"BPzGhkyxhzAvaPewB+4hth4JYly7NjeWPATha+bwIwlVQ6Wv+oWmHTsAtihjJ6oKZwJ58MSGfDF2axgJ",
+	"NUWNRpEMgvYA7Jpg36uu/BmkUg69L4vrm7PT0wS2mjY2ECjti0zugIejH0aufRGsPDYSYwH4R+maodN7",
+	"ht57rbmg+3XEmE3ItRoPXnCOE7GmgAJdioa41DWCTMiXVVHHvEAnKTg8LKtLNJBxv8r3aBs9+0PF0F9c",
+	"wTENTtPuhuNQukoW+i84YuSH+CAz+IKxsxfSkWlGpilUgPcRLZFIOoRM55p76nIHmjzEng9fgyVZVyeW",
+	"5SRVpB6kUWCQ2zB58KktcA6LCvNzh1ItunmSwKJb+/186zRhSVIdLLVZT+vxvqyM7YD/6+T86v2EDW67",
+	"dumAOJVps7KHAXN+9R46YyDQRhLILLNbDyEOmWQbLbCbu1V2RzVpg9oB43vatOr2jkvrMJWe+DKRiDt0",
+	"vlTi1fRkesJwsgUaWWgxF2fTk+mZSOIOE2M5i83L5bPvscns+dsaaQR1JZ0H2W+lwWuzZg2BeisTf6lk",
+	"l6bHNGX9bUSitua9auV2C6NIejvap/HC0JLMyh1uf3tvsTo9OTlUVRq6Wb2S7BPx+jH0vW0tMr1+DtNP",
+	"z2B69WT1OH9Dnku3az1dBqIXRJEIkmsfB7Hu51sWMGvgGYum9XRw7loiyCrQDGWHKeo7HIW0BF9gqlc6",
+	"jbguS0K6sR4NNBV8CufGml1ug4+7VrN8eTQKvM3uUI2MaOWwIwtKN3JyXX2uR54/Nr2qZkTDXV8xYsuK",
+	"5OoxPyq1eH1ytmhrDmd0rG8xLcshcwDqxh/VjwH09Naq3YvtvWNjBMeqK/HbZLvdTli/SXAZmtSqcrx6",
+	"+hVx2N73ewq5gPv/TcK9eg7T2X8rS0+fwfTm5PQ5qV21Zi6R+6Tty59u97fdxL850l+Op34wTfI/2CD+",
+	"3tL6tgysnM0HBeBQz4gsfphOHdH/B73h3yzzHWeNev94xLftfDwa5Wuk4Iwv23s1iNYXrOpBpJpZ6hKP",
+	"qqzxZdirseX86v00tSO182ekimQY65GNiKe03lpUa1HPc18Dul07zlVbwuHftUc3o0M4epGqXps98rfv",
+	"hn9Per8KGTTegsnhCLgYJlSPhm7zp/GRBbZHf/ZE+tdPoz/96Un0jyqm3f/ij62jPyONeRwkVKCq86o6",
+	"5JSK4t1dDeDgMjEXMx7G97cNw6HtoQlnE3PfArm+ZJ/cZ+/9BculkWvM0YxL6ZWA/e3+XwMAE+mWWy0Z",
+	"AAA=",
+}
+
+// GetSwagger returns the content of the embedded swagger specification file
+// or error if failed to decode
+func decodeSpec() ([]byte, error) {
+	zipped, err := base64.StdEncoding.DecodeString(strings.Join(swaggerSpec, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding spec: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var rawSpec = decodeSpecCached()
+
+// a naive cached of a decoded swagger spec
+func decodeSpecCached() func() ([]byte, error) {
+	data, err := decodeSpec()
+	return func() ([]byte, error) {
+		return data, err
+	}
+}
+
+// Constructs a synthetic filesystem for resolving external references when loading openapi specifications.
+func PathToRawSpec(pathToFile string) map[string]func() ([]byte, error) {
+	res := make(map[string]func() ([]byte, error))
+	if len(pathToFile) > 0 {
+		res[pathToFile] = rawSpec
+	}
+
+	return res
+}
+
+// GetSwagger returns the Swagger specification corresponding to the generated code
+// in this file. The external references of Swagger specification are resolved.
+// The logic of resolving external references is tightly connected to "import-mapping" feature.
+// Externally referenced files must be embedded in the corresponding golang packages.
+// Urls can be supported but this task was out of the scope.
+func GetSwagger() (swagger *openapi3.T, err error) {
+	resolvePath := PathToRawSpec("")
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(loader *openapi3.Loader, url *url.URL) ([]byte, error) {
+		pathToFile := url.String()
+		pathToFile = path.Clean(pathToFile)
+		getSpec, ok := resolvePath[pathToFile]
+		if !ok {
+			err1 := fmt.Errorf("path not found: %s", pathToFile)
+			return nil, err1
+		}
+		return getSpec()
+	}
+	var specData []byte
+	specData, err = rawSpec()
+	if err != nil {
+		return
+	}
+	swagger, err = loader.LoadFromData(specData)
+	if err != nil {
+		return
+	}
+	return
+}
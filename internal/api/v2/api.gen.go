@@ -0,0 +1,377 @@
+// Package apiv2 provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.4.1 DO NOT EDIT.
+package apiv2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/oapi-codegen/runtime"
+)
+
+const (
+	ApiKeyScopes          = "apiKey.Scopes"
+	SubscriberTokenScopes = "subscriberToken.Scopes"
+)
+
+// Defines values for Frequency.
+const (
+	Daily  Frequency = "daily"
+	Hourly Frequency = "hourly"
+)
+
+// Confirmation defines model for Confirmation.
+type Confirmation struct {
+	Token string `json:"token"`
+}
+
+// FieldError defines model for FieldError.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Frequency Frequency of updates
+type Frequency string
+
+// Problem Error response (RFC 7807)
+type Problem struct {
+	Detail   *string       `json:"detail,omitempty"`
+	Errors   *[]FieldError `json:"errors,omitempty"`
+	Instance *string       `json:"instance,omitempty"`
+	Status   int           `json:"status"`
+	Title    string        `json:"title"`
+	Type     string        `json:"type"`
+}
+
+// Subscription defines model for Subscription.
+type Subscription struct {
+	City      string    `json:"city"`
+	Confirmed bool      `json:"confirmed"`
+	CreatedAt time.Time `json:"createdAt"`
+	Email     string    `json:"email"`
+
+	// Frequency Frequency of updates
+	Frequency Frequency `json:"frequency"`
+	Id        int       `json:"id"`
+}
+
+// SubscriptionCreate defines model for SubscriptionCreate.
+type SubscriptionCreate struct {
+	City string `json:"city"`
+
+	// Email Email address (RFC 5322, without display name)
+	Email string `json:"email"`
+
+	// Frequency Frequency of updates
+	Frequency Frequency `json:"frequency"`
+}
+
+// SubscriptionPatch defines model for SubscriptionPatch.
+type SubscriptionPatch struct {
+	City *string `json:"city,omitempty"`
+
+	// Frequency Frequency of updates
+	Frequency *Frequency `json:"frequency,omitempty"`
+}
+
+// SubscriptionId defines model for SubscriptionId.
+type SubscriptionId = int
+
+// CreateSubscriptionJSONRequestBody defines body for CreateSubscription for application/json ContentType.
+type CreateSubscriptionJSONRequestBody = SubscriptionCreate
+
+// UpdateSubscriptionJSONRequestBody defines body for UpdateSubscription for application/json ContentType.
+type UpdateSubscriptionJSONRequestBody = SubscriptionPatch
+
+// CreateConfirmationJSONRequestBody defines body for CreateConfirmation for application/json ContentType.
+type CreateConfirmationJSONRequestBody = Confirmation
+
+// ServerInterface represents all server handlers.
+type ServerInterface interface {
+	// Create subscription
+	// (POST /subscriptions)
+	CreateSubscription(c *gin.Context)
+	// Delete subscription
+	// (DELETE /subscriptions/{id})
+	DeleteSubscription(c *gin.Context, id SubscriptionId)
+	// Get subscription
+	// (GET /subscriptions/{id})
+	GetSubscription(c *gin.Context, id SubscriptionId)
+	// Change city or frequency of subscription
+	// (PATCH /subscriptions/{id})
+	UpdateSubscription(c *gin.Context, id SubscriptionId)
+	// Confirm subscription
+	// (POST /subscriptions/{id}/confirmations)
+	CreateConfirmation(c *gin.Context, id SubscriptionId)
+}
+
+// ServerInterfaceWrapper converts contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler            ServerInterface
+	HandlerMiddlewares []MiddlewareFunc
+	ErrorHandler       func(*gin.Context, error, int)
+}
+
+type MiddlewareFunc func(c *gin.Context)
+
+// CreateSubscription operation middleware
+func (siw *ServerInterfaceWrapper) CreateSubscription(c *gin.Context) {
+
+	c.Set(ApiKeyScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CreateSubscription(c)
+}
+
+// DeleteSubscription operation middleware
+func (siw *ServerInterfaceWrapper) DeleteSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(SubscriberTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteSubscription(c, id)
+}
+
+// GetSubscription operation middleware
+func (siw *ServerInterfaceWrapper) GetSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(SubscriberTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetSubscription(c, id)
+}
+
+// UpdateSubscription operation middleware
+func (siw *ServerInterfaceWrapper) UpdateSubscription(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(SubscriberTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.UpdateSubscription(c, id)
+}
+
+// CreateConfirmation operation middleware
+func (siw *ServerInterfaceWrapper) CreateConfirmation(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id SubscriptionId
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CreateConfirmation(c, id)
+}
+
+// GinServerOptions provides options for the Gin server.
+type GinServerOptions struct {
+	BaseURL      string
+	Middlewares  []MiddlewareFunc
+	ErrorHandler func(*gin.Context, error, int)
+}
+
+// RegisterHandlers creates http.Handler with routing matching OpenAPI spec.
+func RegisterHandlers(router gin.IRouter, si ServerInterface) {
+	RegisterHandlersWithOptions(router, si, GinServerOptions{})
+}
+
+// RegisterHandlersWithOptions creates http.Handler with additional options
+func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options GinServerOptions) {
+	errorHandler := options.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(c *gin.Context, err error, statusCode int) {
+			c.JSON(statusCode, gin.H{"msg": err.Error()})
+		}
+	}
+
+	wrapper := ServerInterfaceWrapper{
+		Handler:            si,
+		HandlerMiddlewares: options.Middlewares,
+		ErrorHandler:       errorHandler,
+	}
+
+	router.POST(options.BaseURL+"/subscriptions", wrapper.CreateSubscription)
+	router.DELETE(options.BaseURL+"/subscriptions/:id", wrapper.DeleteSubscription)
+	router.GET(options.BaseURL+"/subscriptions/:id", wrapper.GetSubscription)
+	router.PATCH(options.BaseURL+"/subscriptions/:id", wrapper.UpdateSubscription)
+	router.POST(options.BaseURL+"/subscriptions/:id/confirmations", wrapper.CreateConfirmation)
+}
+
+// Base64 encoded, gzipped, json marshaled Swagger object
+var swaggerSpec = []string{
+
+	"H4sIAAAAAAAC/9xYX2/bNhD/KsRtDxum2IqSoKv2lHlrV6zAjLbDCgQBzEhni61EauQprRDouw8kZVmy",
+	"5SROm7bYm0Qe73783V/pBhJVlEqiJAPxDZRc8wIJtXt7XV2ZRIuShJIvUrsiJMRQcsogAMkLhBhECgFo",
+	"/LcSGlOISVcYgEkyLLg9UQgpiqqA+DgAqkt3QhKuUEPTNPakKZU06AzOtbrKsbCPiZKEkuwjL8tcJNyi",
+	"mJZe4qd3Rkm7tzH0vcYlxPDddHOjqd8107VeZzHF7lIQw+9aK83WKMAKtKes0pmSS6EL7oVvoNSqRE3C",
+	"wyX1Ht1yezFDWsgVNE2fkItW7LK7v7p6hwlBE8AzgXnqEOwqX9q9EeUBFGgMX+Hdhr2KzYFRCPYAyqS2",
+	"6obUdFtMLVlVppzQQAAorTsvIFOVzmsIIOUir3vKN0h7/ryNdfbDq2cz9uTn8MmPEGzRkCJxkY/ygFaJ",
+	"ExKEhbkrCHpkNx1WrjWv7buQhrhMcNSSIU6V6W11IRwACcrHT/mFO6PD7q7VdKbGXNVPx914SQTVozAS",
+	"H8PYD6YrpXLk0m1r5ITpuUu1pbLBDjFYZx+RKBy0HeKLfS5Z9qPpVmd0gpb7dIzaLZ5cnfGWA3/Zvrn+",
+	"Nft3uovHmZPcz2bBP75EuaIM4ujsLIBCyPX78W3MbIW7XWY8TTUa46P97CSKAvZBUKYqYqkwZc5rZmuq",
+	"zYFts5+F6C1C93J5F2dzTknW1vZ5j7Xj4POQ+MC7bWG2aYtJpQXVr628h8RL8SeO1Lq/3APP2Xt05a7k",
+	"miRqluQCJTk/sYXpkWDiD1oQLphJlEtg1xoz5CnqTXN8e3Q+f3FkLW4qjkdg4Xl1V6jfrBvJEJRbZkut",
+	"Cpb0GhFTmn1AThlqliMRaouYMmFYHyG0nczlO3KNegMiIyp9NxRyqXYtv0KjKp0gU9reH1N2Pn/Blkqz",
+	"gku+EnLVIRiQMukqWQz/tALPlMaEG3IqriMI4Bq18XaiSTgJLReqRMlLATGcTMLJCQRuynAumw4s2JVS",
+	"GdrF7FPZsEp2pWAAjnGZMoMyNUM2PYUTNleG7MVcXriWt09R2wuZIMNsjDvNXdS6N43eEmXYGgjadpc6",
+	"8lw8RWG0cNJSscVL5UecxYSdSyXrQlWmDT/jYDOj8mtM2ex8/mb2x/mmewrJFm+PZrykJONHr9rlBfPB",
+	"+EtXZNYHUfKrHFOLrWZcI9NoUwbTFtZpeNLCwtbx77G2nrW5zdfDYMv362HEORIM/arS+pYp7rDpbaRe",
+	"N02zPXRuz5JRePwoCMaGyP4+a5tPMBZl1pEEQVsnHM6134dotmcGazMKo69zI/woDA0Sa//lGF9x4QaL",
+	"0zDch6Jz1GYyt/LHB8qfHCj/9DD56DD5szA6QL7XniC+uGmCTW+6uGwubXcoCq7rLtG2azvxlbFtfLB8",
+	"afUOC+b0RqSNr5Y5+lFnmMe/ufWdPB6k0uluuR1EiNedPsyNp4fJH4cPp3m3526T7dm4J9kBrJB2GX2O",
+	"dDud4VfJ4/+Bd54j3ds1/X8ZF+OQNiLTrX8d1my5nnOH3v3bdf8v3Pf8zH2vtvflgsszMRyOvkzlf8yg",
+	"/MRKfncQzzIuV+gnR6V7g6NaflKRn/Z78s7vvIelwPik7e0MPzf88Eh7PlnWQ7b/pBFuBDdVgem+uXLw",
+	"5+1x8mtg4pueKLtfG99icoVPHzEZm0HieB7umyT2LOrrdfRXOocYprwU0+vIRXd78NbJRrdfwmbzUT8s",
+	"d5fNfwMATjl1FUUXAAA=",
+}
+
+// GetSwagger returns the content of the embedded swagger specification file
+// or error if failed to decode
+func decodeSpec() ([]byte, error) {
+	zipped, err := base64.StdEncoding.DecodeString(strings.Join(swaggerSpec, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding spec: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var rawSpec = decodeSpecCached()
+
+// a naive cached of a decoded swagger spec
+func decodeSpecCached() func() ([]byte, error) {
+	data, err := decodeSpec()
+	return func() ([]byte, error) {
+		return data, err
+	}
+}
+
+// Constructs a synthetic filesystem for resolving external references when loading openapi specifications.
+func PathToRawSpec(pathToFile string) map[string]func() ([]byte, error) {
+	res := make(map[string]func() ([]byte, error))
+	if len(pathToFile) > 0 {
+		res[pathToFile] = rawSpec
+	}
+
+	return res
+}
+
+// GetSwagger returns the Swagger specification corresponding to the generated code
+// in this file. The external references of Swagger specification are resolved.
+// The logic of resolving external references is tightly connected to "import-mapping" feature.
+// Externally referenced files must be embedded in the corresponding golang packages.
+// Urls can be supported but this task was out of the scope.
+func GetSwagger() (swagger *openapi3.T, err error) {
+	resolvePath := PathToRawSpec("")
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(loader *openapi3.Loader, url *url.URL) ([]byte, error) {
+		pathToFile := url.String()
+		pathToFile = path.Clean(pathToFile)
+		getSpec, ok := resolvePath[pathToFile]
+		if !ok {
+			err1 := fmt.Errorf("path not found: %s", pathToFile)
+			return nil, err1
+		}
+		return getSpec()
+	}
+	var specData []byte
+	specData, err = rawSpec()
+	if err != nil {
+		return
+	}
+	swagger, err = loader.LoadFromData(specData)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,4 @@
+// Package apiv2 contains HTTP server interface generated from api/openapi.v2.yaml.
+package apiv2
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen --config ../../../api/oapi-codegen.v2.yaml ../../../api/openapi.v2.yaml
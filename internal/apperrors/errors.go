@@ -0,0 +1,141 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	Internal Kind = iota
+	NotFound
+	Conflict
+	Expired
+	Validation
+	Unavailable
+	Throttled
+	Unauthorized
+	Forbidden
+)
+
+// Error is a domain error which carries its kind, so transport layer can
+// decide how to report it without knowing where it came from.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// Invalid returns validation error with details about each rejected field.
+func Invalid(message string, fields ...FieldError) *Error {
+	return &Error{Kind: Validation, Message: message, Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns kind of the outermost domain error in chain, or Internal.
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+
+	return Internal
+}
+
+// MessageOf returns message of the outermost domain error in chain, safe to
+// be shown to clients. Internal errors are not disclosed.
+func MessageOf(err error) string {
+	var appErr *Error
+	if errors.As(err, &appErr) && appErr.Kind != Internal {
+		return appErr.Message
+	}
+
+	return "internal server error"
+}
+
+// FieldsOf returns field details of the outermost domain error in chain.
+func FieldsOf(err error) []FieldError {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+
+	return nil
+}
+
+func (k Kind) String() string {
+	switch k {
+	case NotFound:
+		return "not-found"
+	case Conflict:
+		return "conflict"
+	case Expired:
+		return "expired"
+	case Validation:
+		return "validation"
+	case Unavailable:
+		return "unavailable"
+	case Throttled:
+		return "throttled"
+	case Unauthorized:
+		return "unauthorized"
+	case Forbidden:
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
+
+func (k Kind) HTTPStatus() int {
+	switch k {
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Expired:
+		return http.StatusGone
+	case Validation:
+		return http.StatusBadRequest
+	case Unavailable:
+		return http.StatusBadGateway
+	case Throttled:
+		return http.StatusTooManyRequests
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func HTTPStatus(err error) int {
+	return KindOf(err).HTTPStatus()
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/persistance"
+	"github.com/Rabiann/weather-mailer/internal/services"
+)
+
+const apiKeyUsage = "usage: apikey create --name <name> --scopes weather:read,subscriptions:write [--quota <requests per day>] | apikey revoke <id> | apikey list"
+
+// ApiKey runs `apikey create|revoke|list` command.
+func (a *App) ApiKey(args []string) error {
+	configuration, args, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+
+	db, err := bootstrapDatabase(configuration)
+	if err != nil {
+		return err
+	}
+
+	apiKeyService := services.NewApiKeyService(persistance.NewApiKeyRepository(db), persistance.NewAuditRepository(db), persistance.NewUnitOfWork(db))
+	ctx := context.Background()
+	actor := "cli:" + os.Getenv("USER")
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := flags.String("name", "", "name of client owning the key")
+		scopes := flags.String("scopes", "", "comma separated scopes")
+		quota := flags.Int("quota", 0, "requests per day, 0 for unlimited")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *name == "" || *scopes == "" || *quota < 0 {
+			return errors.New(apiKeyUsage)
+		}
+
+		key, raw, err := apiKeyService.CreateKey(actor, *name, strings.Split(*scopes, ","), *quota, ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created key %d for %s, it is shown only once:\n%s\n", key.ID, key.Name, raw)
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(apiKeyUsage)
+		}
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return errors.New(apiKeyUsage)
+		}
+
+		if err := apiKeyService.RevokeKey(actor, uint(id), ctx); err != nil {
+			return err
+		}
+		fmt.Printf("revoked key %d\n", id)
+	case "list":
+		keys, err := apiKeyService.GetKeys(ctx)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			state := "active"
+			if key.RevokedAt != nil {
+				state = "revoked " + key.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			quota := "unlimited"
+			if key.DailyQuota > 0 {
+				quota = fmt.Sprintf("%d/day", key.DailyQuota)
+			}
+			fmt.Printf("%4d %-20s %s… %-40s %-12s used %d times  %s\n", key.ID, key.Name, key.Prefix, key.Scopes, quota, key.UsageCount, state)
+		}
+	default:
+		return fmt.Errorf("unknown apikey command `%s`", args[0])
+	}
+
+	return nil
+}
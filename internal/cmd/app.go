@@ -2,93 +2,285 @@ package cmd
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/Rabiann/weather-mailer/internal/antispam"
 	"github.com/Rabiann/weather-mailer/internal/config"
 	"github.com/Rabiann/weather-mailer/internal/controllers"
 	"github.com/Rabiann/weather-mailer/internal/external"
-	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/logging"
+	"github.com/Rabiann/weather-mailer/internal/metrics"
+	"github.com/Rabiann/weather-mailer/internal/middleware"
+	"github.com/Rabiann/weather-mailer/internal/migrations"
 	"github.com/Rabiann/weather-mailer/internal/notification"
 	"github.com/Rabiann/weather-mailer/internal/persistance"
+	"github.com/Rabiann/weather-mailer/internal/ratelimit"
 	"github.com/Rabiann/weather-mailer/internal/services"
+	"github.com/Rabiann/weather-mailer/internal/tracing"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type App struct{}
 
-func bootstrapDatabase() (*gorm.DB, error) {
-	db := persistance.ConnectToDatabase()
+// application is wiring shared by commands: configuration, logger,
+// database and services built on them. Nothing is started by building it.
+type application struct {
+	configuration   *config.Configuration
+	logger          *slog.Logger
+	db              *gorm.DB
+	shutdownTracing func(context.Context) error
 
-	if err := db.AutoMigrate(&models.Subscription{}); err != nil {
+	subscriptionDataService *services.SubscriptionDataService
+	subscriptionService     *services.SubscriptionControlService
+	weatherService          *services.WeatherService
+	adminService            *services.AdminService
+	dashboardService        *services.DashboardService
+	apiKeyService           *services.ApiKeyService
+	emailService            *services.MailingService
+	outboxService           *services.OutboxService
+	notifier                notification.Notifier
+}
+
+// bootstrapDatabase connects to database and refuses to continue when its
+// schema does not match migrations embedded into binary.
+func bootstrapDatabase(configuration *config.Configuration) (*gorm.DB, error) {
+	db, err := persistance.ConnectToDatabase(configuration.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&models.Token{}); err != nil {
+	if err := migrator.Verify(context.Background()); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func (a *App) Run() error {
-	configuration, err := config.LoadEnvironment()
+// formSecret returns configured secret for signing forms or random one.
+func formSecret(configuration *config.Configuration, logger *slog.Logger) ([]byte, error) {
+	if configuration.FormSecret != "" {
+		return []byte(configuration.FormSecret), nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	logger.Warn("`FORM_SECRET` is not set, forms rendered before restart will be rejected")
+
+	return secret, nil
+}
+
+func newBotGuard(configuration *config.Configuration, secret []byte) (*antispam.Guard, error) {
+	var captcha antispam.CaptchaVerifier
+	switch name := configuration.Captcha.Provider; name {
+	case "":
+	case "fake":
+		captcha = antispam.NewFakeCaptcha(configuration.Captcha.Secret)
+	default:
+		provider, ok := external.CaptchaProviders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown CAPTCHA provider `%s`", name)
+		}
+		captcha = external.NewCaptchaVerifier(provider, configuration.Captcha.SiteKey, configuration.Captcha.Secret)
+	}
+
+	return antispam.NewGuard(secret, captcha), nil
+}
+
+// start builds application from configuration read from args with flags
+// of command, nil when command has none, logging into logOutput. Caller
+// should close it.
+func start(flags *flag.FlagSet, args []string, logOutput io.Writer) (*application, []string, error) {
+	if flags == nil {
+		flags = flag.NewFlagSet("weather-mailer", flag.ContinueOnError)
+	}
+
+	configuration, rest, err := config.LoadWithFlags(flags, args)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	logger, err := logging.New(logOutput, configuration.LogFormat, configuration.LogLevel)
+	if err != nil {
+		return nil, nil, err
 	}
+	slog.SetDefault(logger)
 
-	db, err := bootstrapDatabase()
+	shutdownTracing, err := tracing.Setup(configuration.TraceExporter, context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	app := &application{configuration: configuration, logger: logger, shutdownTracing: shutdownTracing}
+	if err := app.build(); err != nil {
+		app.close()
+		return nil, nil, err
+	}
+
+	return app, rest, nil
+}
+
+func (a *application) build() error {
+	configuration, logger := a.configuration, a.logger
+
+	db, err := bootstrapDatabase(configuration)
 	if err != nil {
 		return err
 	}
+	a.db = db
 
 	subscriptionRepository := persistance.NewSubscriptionRepository(db)
 	tokenRepository := persistance.NewTokenRepository(db)
-	weatherProvider := external.NewWeatherProvider(configuration)
+	outboxRepository := persistance.NewOutboxRepository(db)
+	deliveryRepository := persistance.NewDeliveryRepository(db)
+	auditRepository := persistance.NewAuditRepository(db)
+	apiKeyRepository := persistance.NewApiKeyRepository(db)
+	statisticsRepository := persistance.NewStatisticsRepository(db)
+	unitOfWork := persistance.NewUnitOfWork(db)
+	weatherProvider := external.NewWeatherProvider(configuration, logger)
+
+	if err := metrics.RegisterSubscribers(statisticsRepository, logger); err != nil {
+		return err
+	}
 
-	weatherService := services.NewWeatherService(weatherProvider)
-	subscriptionDataService := services.NewSubscriptionService(subscriptionRepository)
+	a.weatherService = services.NewWeatherService(weatherProvider)
+	a.subscriptionDataService = services.NewSubscriptionService(subscriptionRepository)
 	tokenService := services.NewTokenService(tokenRepository)
-	emailService, err := services.NewMailingService(configuration)
+	deliveryService := services.NewDeliveryService(deliveryRepository)
+	a.adminService = services.NewAdminService(subscriptionRepository, tokenRepository, deliveryRepository, auditRepository, unitOfWork)
+	a.dashboardService = services.NewDashboardService(statisticsRepository, weatherProvider)
+	a.apiKeyService = services.NewApiKeyService(apiKeyRepository, auditRepository, unitOfWork)
+	a.emailService, err = services.NewMailingService(configuration, logger)
 	if err != nil {
 		return err
 	}
 
-	subscriptionService := services.NewSubscriptionBusinessService(subscriptionDataService, tokenService, emailService, configuration.BaseUrl)
-	notifier := notification.NewNotifier(weatherService, subscriptionDataService, emailService, tokenService)
-	go notifier.RunNotifier(configuration.BaseUrl)
+	a.outboxService = services.NewOutboxService(outboxRepository, tokenService, a.emailService, deliveryService, unitOfWork, configuration.BaseUrl, logger)
+	a.subscriptionService = services.NewSubscriptionBusinessService(a.subscriptionDataService, tokenService, a.outboxService, a.weatherService, unitOfWork, logger)
+	a.notifier = notification.NewNotifier(a.weatherService, a.subscriptionDataService, a.emailService, tokenService, deliveryService, configuration.Notifier.Concurrency, logger)
+
+	return nil
+}
+
+func (a *application) close() {
+	if err := a.shutdownTracing(context.Background()); err != nil {
+		a.logger.Error("spans not flushed", slog.Any("error", err))
+	}
+}
+
+// healthService checks components command runs, scheduler ones only when
+// it runs notifier.
+func (a *application) healthService(scheduler bool) *services.HealthService {
+	checks := []services.HealthCheck{
+		{Name: "database", Required: true, Checker: persistance.NewHealthRepository(a.db)},
+		{Name: "templates", Required: true, Checker: services.HealthCheckFunc(a.emailService.CheckTemplates)},
+		// letters wait in outbox and next runs catch up, so these only degrade the application
+		{Name: "mailTransport", Checker: services.HealthCheckFunc(a.emailService.CheckTransport)},
+	}
+	if scheduler {
+		checks = append(checks,
+			services.HealthCheck{Name: "scheduler", Required: true, Checker: services.HealthCheckFunc(a.notifier.CheckScheduler)},
+			services.HealthCheck{Name: "notifierRuns", Checker: services.HealthCheckFunc(a.notifier.CheckRuns)},
+		)
+	}
+
+	return services.NewHealthService(checks...)
+}
+
+// newRouter serves metrics and health of application, which every long
+// running command exposes.
+func (a *application) newRouter(healthService *services.HealthService) (*gin.Engine, error) {
+	router := gin.New()
+	// lets services see request context values, such as request ID, through gin context
+	router.ContextWithFallback = true
+	if err := router.SetTrustedProxies(a.configuration.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.Use(gin.Recovery(), middleware.RequestID(), middleware.Tracing(), middleware.AccessLog(a.logger), middleware.Metrics())
+	router.GET("/metrics", gin.WrapH(metrics.Handler(a.logger)))
+
+	healthController := controllers.NewHealthController(healthService)
+	router.GET("/healthz", healthController.Live)
+	router.GET("/readyz", healthController.Ready)
+
+	return router, nil
+}
+
+// registerApi adds public API, subscription form and admin API to router.
+func (a *application) registerApi(router *gin.Engine) error {
+	secret, err := formSecret(a.configuration, a.logger)
+	if err != nil {
+		return err
+	}
 
-	weatherController := controllers.NewWeatherController(weatherService)
-	subscriptionController := controllers.NewSubscriptionController(subscriptionService)
-	router := gin.Default()
-	router.LoadHTMLGlob("templates/*")
-	router.StaticFile("/favicon.ico", "./static/weather.ico")
+	botGuard, err := newBotGuard(a.configuration, secret)
+	if err != nil {
+		return err
+	}
 
-	router.GET("/", func(ctx *gin.Context) {
-		ctx.HTML(http.StatusOK, "subscriptions.html", gin.H{})
-	})
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if a.configuration.RateLimits.Store == config.RateLimitPostgres {
+		rateLimitRepository := persistance.NewRateLimitRepository(a.db)
+		go rateLimitRepository.RunCleanup(time.Hour)
+		rateLimitStore = rateLimitRepository
+	}
 
-	api := router.Group("/api")
-	{
-		api.GET("/weather", weatherController.GetWeather)
-		api.POST("/subscribe", subscriptionController.Subscribe)
-		api.GET("/confirm/:token", subscriptionController.Confirm)
-		api.GET("/unsubscribe/:token", subscriptionController.Unsubscribe)
+	var responseErrors middleware.ResponseErrorHandler
+	if gin.Mode() != gin.ReleaseMode {
+		responseErrors = middleware.LogResponseError
 	}
 
+	return registerApi(router, apiDependencies{
+		weather:          a.weatherService,
+		subscriptions:    a.subscriptionService,
+		subscriptionData: a.subscriptionDataService,
+		admin:            a.adminService,
+		dashboard:        a.dashboardService,
+		apiKeys:          a.apiKeyService,
+		botGuard:         botGuard,
+		rateLimitStore:   rateLimitStore,
+		formSecret:       secret,
+		responseErrors:   responseErrors,
+	}, a.configuration, a.logger)
+}
+
+// startWorker starts notifier and relay of outbox in background.
+func (a *application) startWorker() {
+	go a.outboxService.RunRelay(time.Minute)
+	go a.notifier.RunNotifier(a.configuration.BaseUrl)
+}
+
+// listen serves handler until SIGINT or SIGTERM, then waits for requests
+// in flight.
+func (a *application) listen(handler http.Handler) error {
 	srv := &http.Server{
-		Addr:    ":" + configuration.Port,
-		Handler: router.Handler(),
+		Addr:              fmt.Sprintf(":%d", a.configuration.Server.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: a.configuration.Server.ReadHeaderTimeout,
+		ErrorLog:          slog.NewLogLogger(a.logger.Handler(), slog.LevelError),
 	}
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			a.logger.Error("server stopped", slog.Any("error", err))
+			os.Exit(1)
 		}
 	}()
 
@@ -97,18 +289,95 @@ func (a *App) Run() error {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutdown server.")
+	a.logger.Info("shutting down server")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), a.configuration.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Print("Server Shutdown:", err)
+		a.logger.Error("server shutdown failed", slog.Any("error", err))
 	}
 
 	<-ctx.Done()
-	log.Println("timeout 5 seconds")
-	log.Printf("server exiting")
+	a.logger.Info("server exiting")
 
 	return nil
 }
+
+// Run runs API and notifier in one process, as when no command is given.
+func (a *App) Run(args []string) error {
+	app, rest, err := start(nil, args, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	if len(rest) > 0 {
+		return fmt.Errorf("unknown command `%s`", rest[0])
+	}
+
+	app.startWorker()
+	go reloadOnSignal(args, app.configuration, app.emailService, &app.notifier, app.logger)
+
+	router, err := app.newRouter(app.healthService(true))
+	if err != nil {
+		return err
+	}
+
+	if err := app.registerApi(router); err != nil {
+		return err
+	}
+
+	return app.listen(router.Handler())
+}
+
+// Serve runs `serve` command, which serves API without sending reports,
+// so that API and notifier can be deployed separately.
+func (a *App) Serve(args []string) error {
+	app, rest, err := start(nil, args, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	if len(rest) > 0 {
+		return errors.New("usage: serve")
+	}
+
+	go reloadOnSignal(args, app.configuration, app.emailService, nil, app.logger)
+
+	router, err := app.newRouter(app.healthService(false))
+	if err != nil {
+		return err
+	}
+
+	if err := app.registerApi(router); err != nil {
+		return err
+	}
+
+	return app.listen(router.Handler())
+}
+
+// Worker runs `worker` command, which sends reports and relays outbox.
+// Only metrics and health are served.
+func (a *App) Worker(args []string) error {
+	app, rest, err := start(nil, args, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	if len(rest) > 0 {
+		return errors.New("usage: worker")
+	}
+
+	app.startWorker()
+	go reloadOnSignal(args, app.configuration, app.emailService, &app.notifier, app.logger)
+
+	router, err := app.newRouter(app.healthService(true))
+	if err != nil {
+		return err
+	}
+
+	return app.listen(router.Handler())
+}
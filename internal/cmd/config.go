@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Rabiann/weather-mailer/internal/config"
+)
+
+const configUsage = "usage: config [--config <file>] [--secrets <file>] print | config encrypt <plain yaml> <encrypted file>"
+
+// Config runs `config print|encrypt` command.
+func (a *App) Config(args []string) error {
+	configuration, args, err := config.Read(args)
+	if configuration == nil {
+		return err
+	}
+	readErr := err
+
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+
+	switch args[0] {
+	case "print":
+		if len(args) != 1 {
+			return errors.New(configUsage)
+		}
+
+		if err := configuration.Print(os.Stdout); err != nil {
+			return err
+		}
+
+		// configuration is printed even when invalid, as that is when it is looked at
+		if err := errors.Join(readErr, configuration.Validate()); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+	case "encrypt":
+		if len(args) != 3 {
+			return errors.New(configUsage)
+		}
+
+		rawKey, _, err := config.LookupEnv(config.SecretsKeyEnv)
+		if err != nil {
+			return err
+		}
+
+		key, err := config.ParseSecretsKey(rawKey)
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := config.EncryptSecrets(plaintext, key)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(args[2], encrypted, 0o600); err != nil {
+			return err
+		}
+		fmt.Printf("encrypted %s into %s\n", args[1], args[2])
+	default:
+		return fmt.Errorf("unknown config command `%s`", args[0])
+	}
+
+	return nil
+}
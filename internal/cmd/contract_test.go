@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/api"
+	apiadmin "github.com/Rabiann/weather-mailer/internal/api/admin"
+	apiv2 "github.com/Rabiann/weather-mailer/internal/api/v2"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	adminToken = "admin-token"
+	// missingId is subscription admin stub does not know.
+	missingId = 404
+)
+
+type (
+	stubAdmin struct{}
+
+	// weeklySubscriptionData returns subscription with frequency API does
+	// not define.
+	weeklySubscriptionData struct {
+		stubSubscriptionData
+	}
+
+	// responseErrors collects responses not matching API specification.
+	responseErrors struct {
+		mu     sync.Mutex
+		errors []string
+	}
+
+	contractCase struct {
+		// operation is `<api>.<operationId>` request exercises.
+		operation string
+		method    string
+		path      string
+		body      string
+		headers   map[string]string
+		status    int
+	}
+)
+
+var testSubscription = models.Subscription{
+	ID:        1,
+	Email:     "user@example.com",
+	City:      "Kyiv",
+	Frequency: models.FrequencyDaily,
+	Confirmed: true,
+	CreatedAt: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC),
+}
+
+func (stubAdmin) SearchSubscriptions(models.SubscriptionFilter, context.Context) ([]models.Subscription, int64, error) {
+	return []models.Subscription{testSubscription}, 1, nil
+}
+
+func (stubAdmin) GetSubscriptionDetails(id uint, _ context.Context) (models.SubscriptionDetails, error) {
+	if id == missingId {
+		return models.SubscriptionDetails{}, apperrors.New(apperrors.NotFound, "subscription not found")
+	}
+
+	return models.SubscriptionDetails{
+		Subscription: testSubscription,
+		Tokens:       []models.Token{{Hash: strings.Repeat("ab", 32), Expires: testSubscription.CreatedAt.Add(24 * time.Hour), CreatedAt: testSubscription.CreatedAt}},
+		Deliveries:   []models.Delivery{{Kind: models.DeliveryConfirmation, Status: "failed", Error: "provider is down", CreatedAt: testSubscription.CreatedAt}},
+	}, nil
+}
+
+func (stubAdmin) ConfirmSubscription(string, uint, context.Context) (models.Subscription, error) {
+	return testSubscription, nil
+}
+
+func (stubAdmin) DeactivateSubscription(string, uint, context.Context) (models.Subscription, error) {
+	subscription := testSubscription
+	subscription.Confirmed = false
+	return subscription, nil
+}
+
+func (stubAdmin) DeleteSubscription(string, uint, context.Context) error { return nil }
+
+func (stubAdmin) GetAuditRecords(int, int, context.Context) ([]models.AuditRecord, int64, error) {
+	return []models.AuditRecord{{ID: 1, Actor: "operator", Action: "confirm", SubscriptionID: 1, CreatedAt: testSubscription.CreatedAt}}, 1, nil
+}
+
+func (weeklySubscriptionData) GetSubscriptionById(id uint, _ context.Context) (models.Subscription, error) {
+	subscription := testSubscription
+	subscription.ID = id
+	subscription.Frequency = "weekly"
+	return subscription, nil
+}
+
+func (r *responseErrors) record(ctx *gin.Context, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors = append(r.errors, fmt.Sprintf("%s %s: %v", ctx.Request.Method, ctx.Request.URL, err))
+}
+
+func (r *responseErrors) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errors := r.errors
+	r.errors = nil
+	return errors
+}
+
+// newContractRouter serves API with admin part on, validating responses
+// into errors.
+func newContractRouter(t *testing.T, dependencies apiDependencies, errors *responseErrors) http.Handler {
+	configuration := testConfiguration()
+	configuration.AdminTokens = map[string]string{"operator": adminToken}
+
+	dependencies.admin = stubAdmin{}
+	dependencies.responseErrors = errors.record
+	return newTestRouter(t, configuration, dependencies)
+}
+
+func (c contractCase) serve(router http.Handler) *httptest.ResponseRecorder {
+	var body *strings.Reader
+	if c.body != "" {
+		body = strings.NewReader(c.body)
+	}
+
+	var request *http.Request
+	if body != nil {
+		request = httptest.NewRequest(c.method, c.path, body)
+		request.Header.Set("Content-Type", gin.MIMEJSON)
+	} else {
+		request = httptest.NewRequest(c.method, c.path, nil)
+	}
+	for name, value := range c.headers {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// specOperations lists `<api>.<operationId>` of every operation in specs.
+func specOperations(t *testing.T) []string {
+	t.Helper()
+
+	specs := map[string]func() (*openapi3.T, error){
+		"v1":    api.GetSwagger,
+		"v2":    apiv2.GetSwagger,
+		"admin": apiadmin.GetSwagger,
+	}
+
+	var operations []string
+	for name, load := range specs {
+		spec, err := load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range spec.Paths.Map() {
+			for _, operation := range path.Operations() {
+				operations = append(operations, name+"."+operation.OperationID)
+			}
+		}
+	}
+
+	return operations
+}
+
+// Every operation of public, v2 and admin API is called through the whole
+// router, successful and rejected, and every response is checked against
+// specification.
+func TestResponsesMatchSpecification(t *testing.T) {
+	token := uuid.New().String()
+	bearer := map[string]string{"Authorization": "Bearer " + token}
+	admin := map[string]string{"Authorization": "Bearer " + adminToken}
+	subscription := `{"email": "user@example.com", "city": "Kyiv", "frequency": "daily"}`
+
+	cases := []contractCase{
+		{operation: "v1.getWeather", method: http.MethodGet, path: "/api/weather?city=Kyiv", status: http.StatusOK},
+		{operation: "v1.getWeather", method: http.MethodGet, path: "/api/weather", status: http.StatusBadRequest},
+		{operation: "v1.getWeather", method: http.MethodGet, path: "/api/weather?city=Kyiv", headers: map[string]string{"X-API-Key": "unknown"}, status: http.StatusUnauthorized},
+		{operation: "v1.subscribe", method: http.MethodPost, path: "/api/subscribe", body: subscription, status: http.StatusOK},
+		{operation: "v1.subscribe", method: http.MethodPost, path: "/api/subscribe", body: `{"email": "user@example.com", "city": "Kyiv", "frequency": "weekly"}`, status: http.StatusBadRequest},
+		{operation: "v1.confirmSubscription", method: http.MethodGet, path: "/api/confirm/" + token, status: http.StatusOK},
+		{operation: "v1.confirmSubscription", method: http.MethodGet, path: "/api/confirm/not-a-token", status: http.StatusBadRequest},
+		{operation: "v1.unsubscribe", method: http.MethodGet, path: "/api/unsubscribe/" + token, status: http.StatusOK},
+		{operation: "v1.unsubscribe", method: http.MethodGet, path: "/api/unsubscribe/not-a-token", status: http.StatusBadRequest},
+
+		{operation: "v2.createSubscription", method: http.MethodPost, path: "/api/v2/subscriptions", body: subscription, status: http.StatusCreated},
+		{operation: "v2.createSubscription", method: http.MethodPost, path: "/api/v2/subscriptions", body: `{"email": "pending@example.com", "city": "Kyiv", "frequency": "daily"}`, status: http.StatusAccepted},
+		{operation: "v2.createSubscription", method: http.MethodPost, path: "/api/v2/subscriptions", body: `{"email": "user@example.com"}`, status: http.StatusBadRequest},
+		{operation: "v2.getSubscription", method: http.MethodGet, path: "/api/v2/subscriptions/1", headers: bearer, status: http.StatusOK},
+		{operation: "v2.getSubscription", method: http.MethodGet, path: "/api/v2/subscriptions/1", status: http.StatusUnauthorized},
+		{operation: "v2.updateSubscription", method: http.MethodPatch, path: "/api/v2/subscriptions/1", body: `{"city": "Lviv"}`, headers: bearer, status: http.StatusOK},
+		{operation: "v2.updateSubscription", method: http.MethodPatch, path: "/api/v2/subscriptions/1", body: `{}`, headers: bearer, status: http.StatusBadRequest},
+		{operation: "v2.deleteSubscription", method: http.MethodDelete, path: "/api/v2/subscriptions/1", headers: bearer, status: http.StatusNoContent},
+		{operation: "v2.deleteSubscription", method: http.MethodDelete, path: "/api/v2/subscriptions/1", status: http.StatusUnauthorized},
+		{operation: "v2.createConfirmation", method: http.MethodPost, path: "/api/v2/subscriptions/1/confirmations", body: `{"token": "` + token + `"}`, status: http.StatusCreated},
+		{operation: "v2.createConfirmation", method: http.MethodPost, path: "/api/v2/subscriptions/1/confirmations", body: `{}`, status: http.StatusBadRequest},
+
+		{operation: "admin.listSubscriptions", method: http.MethodGet, path: "/admin/subscriptions?city=Kyiv&confirmed=true", headers: admin, status: http.StatusOK},
+		{operation: "admin.listSubscriptions", method: http.MethodGet, path: "/admin/subscriptions?pageSize=1000", headers: admin, status: http.StatusBadRequest},
+		{operation: "admin.listSubscriptions", method: http.MethodGet, path: "/admin/subscriptions", status: http.StatusUnauthorized},
+		{operation: "admin.getSubscriptionDetails", method: http.MethodGet, path: "/admin/subscriptions/1", headers: admin, status: http.StatusOK},
+		{operation: "admin.getSubscriptionDetails", method: http.MethodGet, path: fmt.Sprintf("/admin/subscriptions/%d", missingId), headers: admin, status: http.StatusNotFound},
+		{operation: "admin.deleteSubscription", method: http.MethodDelete, path: "/admin/subscriptions/1", headers: admin, status: http.StatusNoContent},
+		{operation: "admin.confirmSubscription", method: http.MethodPost, path: "/admin/subscriptions/1/confirm", headers: admin, status: http.StatusOK},
+		{operation: "admin.deactivateSubscription", method: http.MethodPost, path: "/admin/subscriptions/1/deactivate", headers: admin, status: http.StatusOK},
+		{operation: "admin.listAuditRecords", method: http.MethodGet, path: "/admin/audit?page=2", headers: admin, status: http.StatusOK},
+	}
+
+	errors := &responseErrors{}
+	subscriptions := &stubSubscriptions{unconfirmed: map[string]bool{"pending@example.com": true}}
+	router := newContractRouter(t, testDependencies(subscriptions), errors)
+
+	covered := map[string]bool{}
+	for _, c := range cases {
+		covered[strings.ToLower(c.operation)] = true
+
+		recorder := c.serve(router)
+		if recorder.Code != c.status {
+			t.Errorf("%s %s responded with %d, want %d: %s", c.method, c.path, recorder.Code, c.status, recorder.Body)
+		}
+		for _, err := range errors.take() {
+			t.Errorf("response does not match specification: %s", err)
+		}
+	}
+
+	for _, operation := range specOperations(t) {
+		if !covered[strings.ToLower(operation)] {
+			t.Errorf("operation %s is not checked", operation)
+		}
+	}
+}
+
+// Guards the test above against passing only because responses are not
+// validated at all.
+func TestResponseMismatchIsReported(t *testing.T) {
+	dependencies := testDependencies(&stubSubscriptions{})
+	dependencies.subscriptionData = weeklySubscriptionData{}
+	errors := &responseErrors{}
+	router := newContractRouter(t, dependencies, errors)
+
+	request := contractCase{
+		method:  http.MethodGet,
+		path:    "/api/v2/subscriptions/1",
+		headers: map[string]string{"Authorization": "Bearer " + uuid.New().String()},
+	}
+	request.serve(router)
+
+	if len(errors.take()) == 0 {
+		t.Fatal("frequency outside of specification was not reported")
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/migrations"
+	"github.com/Rabiann/weather-mailer/internal/persistance"
+)
+
+// Migrate runs `migrate up|down|status` command.
+func (a *App) Migrate(args []string) error {
+	configuration, args, err := config.LoadDatabase(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	db, err := persistance.ConnectToDatabase(configuration.Database)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			fmt.Println("nothing to roll back")
+			return nil
+		}
+		fmt.Printf("rolled back %04d_%s\n", migration.Version, migration.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (checksum mismatch)"
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command `%s`", args[0])
+	}
+
+	return nil
+}
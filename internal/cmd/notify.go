@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/notification"
+	"github.com/google/uuid"
+)
+
+// NotifyOnce runs `notify-once --period daily|hourly [--dry-run [--out <dir>]]`
+// command, which runs pipeline of period right away, e.g. after failed
+// scheduled run. Results are printed to stdout and logs to stderr.
+func (a *App) NotifyOnce(args []string) error {
+	flags := flag.NewFlagSet("notify-once", flag.ContinueOnError)
+	periodName := flags.String("period", "", "period of subscriptions to notify, `daily` or `hourly`")
+	dryRun := flags.Bool("dry-run", false, "render letters instead of sending them")
+	out := flags.String("out", "", "`directory` dry run writes letters into, stdout when not given")
+
+	app, rest, err := start(flags, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	if len(rest) > 0 || (*out != "" && !*dryRun) {
+		return errors.New("usage: notify-once --period daily|hourly [--dry-run [--out <dir>]]")
+	}
+
+	period, err := notification.ParsePeriod(*periodName)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !*dryRun {
+		return app.notifier.Run(period, app.configuration.BaseUrl, ctx)
+	}
+
+	var sink notification.LetterSink = notification.NewStreamSink(os.Stdout)
+	if *out != "" {
+		if sink, err = notification.NewDirectorySink(*out); err != nil {
+			return err
+		}
+	}
+
+	summary, err := app.notifier.DryRun(period, app.configuration.BaseUrl, sink, ctx)
+	if err != nil {
+		return err
+	}
+
+	printSummary(summary, os.Stdout)
+	return nil
+}
+
+// printSummary lists recipients of dry run by city.
+func printSummary(summary notification.DryRunSummary, w io.Writer) {
+	cities := make([]string, 0, len(summary.Recipients))
+	for city := range summary.Recipients {
+		cities = append(cities, city)
+	}
+	sort.Strings(cities)
+
+	for _, city := range cities {
+		recipients := summary.Recipients[city]
+		sort.Strings(recipients)
+		fmt.Fprintf(w, "%s (%d): %s\n", city, len(recipients), strings.Join(recipients, ", "))
+	}
+	fmt.Fprintf(w, "%d %s letters rendered, %d failed\n", summary.Letters(), summary.Period, summary.Failed)
+}
+
+// SendTestEmail runs `send-test-email --to <email> --city <city>` command,
+// which sends weather report of city to address, checking provider,
+// template and transport at once. Nothing is recorded in database.
+func (a *App) SendTestEmail(args []string) error {
+	flags := flag.NewFlagSet("send-test-email", flag.ContinueOnError)
+	to := flags.String("to", "", "`email` to send report to")
+	city := flags.String("city", "", "`city` to report weather of")
+	periodName := flags.String("period", models.FrequencyDaily, "period shown in subject, `daily` or `hourly`")
+
+	app, rest, err := start(flags, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	if len(rest) > 0 || *to == "" || *city == "" {
+		return errors.New("usage: send-test-email --to <email> --city <city> [--period daily|hourly]")
+	}
+
+	period, err := notification.ParsePeriod(*periodName)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	weather, err := app.weatherService.GetWeather(*city, ctx)
+	if err != nil {
+		return err
+	}
+
+	subscriber := models.Subscriber{
+		Recipient: *to,
+		Period:    period.String(),
+		City:      *city,
+	}
+
+	// there is no subscription to cancel, so letter carries link of no token
+	url := fmt.Sprintf("%s/api/unsubscribe/%s", app.configuration.BaseUrl, uuid.Nil)
+	if err := app.emailService.SendWeatherReport(&subscriber, &weather, url, ctx); err != nil {
+		return err
+	}
+	fmt.Printf("sent %s report for %s to %s\n", period, *city, *to)
+
+	return nil
+}
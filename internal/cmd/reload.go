@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/notification"
+	"github.com/Rabiann/weather-mailer/internal/services"
+)
+
+// reloadOnSignal reads configuration from args again on every SIGHUP and
+// applies settings tagged `reload` together with letter templates, notifier
+// is nil when command does not run it. Nothing is applied when new
+// configuration or some template is invalid.
+func reloadOnSignal(args []string, configuration *config.Configuration, emailService *services.MailingService, notifier *notification.Notifier, logger *slog.Logger) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		next, _, err := config.Load(args)
+		if err != nil {
+			logger.Error("configuration not reloaded", slog.Any("error", err))
+			continue
+		}
+
+		if err := emailService.Reload(next.MailTimeout); err != nil {
+			logger.Error("configuration not reloaded", slog.Any("error", err))
+			continue
+		}
+		if notifier != nil {
+			notifier.SetConcurrency(next.Notifier.Concurrency)
+		}
+
+		// compared with configuration of start, as these are never applied
+		if keys := configuration.RestartRequired(next); len(keys) > 0 {
+			logger.Warn("changed settings take effect after restart", slog.Any("settings", keys))
+		}
+
+		logger.Info("configuration reloaded",
+			slog.Duration("mail_timeout", next.MailTimeout),
+			slog.Int("notifier_concurrency", next.Notifier.Concurrency),
+		)
+	}
+}
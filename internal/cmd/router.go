@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Rabiann/weather-mailer/internal/api"
+	apiadmin "github.com/Rabiann/weather-mailer/internal/api/admin"
+	apiv2 "github.com/Rabiann/weather-mailer/internal/api/v2"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/controllers"
+	"github.com/Rabiann/weather-mailer/internal/middleware"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// apiDependencies are what API is served with. Services are interfaces
+	// of controllers, so that router can be driven with stubs in tests.
+	apiDependencies struct {
+		weather          controllers.WeatherService
+		subscriptions    subscriptionService
+		subscriptionData controllers.SubscriptionDataService
+		admin            controllers.AdminService
+		dashboard        controllers.DashboardService
+		apiKeys          middleware.ApiKeyAuthenticator
+		botGuard         controllers.BotGuard
+		rateLimitStore   ratelimit.Store
+		// formSecret signs CSRF tokens of forms.
+		formSecret []byte
+		// responseErrors receives responses not matching API specification,
+		// responses are not validated when it is nil.
+		responseErrors middleware.ResponseErrorHandler
+	}
+
+	subscriptionService interface {
+		controllers.SubscriptionService
+		controllers.SubscriptionResourceService
+	}
+)
+
+// registerApi adds public API, subscription form and admin API to router,
+// which should already serve metrics and health.
+func registerApi(router *gin.Engine, dependencies apiDependencies, configuration *config.Configuration, logger *slog.Logger) error {
+	if err := controllers.RegisterValidators(); err != nil {
+		return err
+	}
+
+	weatherController := controllers.NewWeatherController(dependencies.weather)
+	subscriptionController := controllers.NewSubscriptionController(dependencies.subscriptions, dependencies.botGuard, logger)
+	server := controllers.NewServer(weatherController, subscriptionController)
+	subscriptionResourceController := controllers.NewSubscriptionResourceController(dependencies.subscriptions, dependencies.subscriptionData, dependencies.botGuard, logger)
+
+	spec, err := api.GetSwagger()
+	if err != nil {
+		return err
+	}
+
+	validator, err := middleware.OpenAPIValidator(spec, "/api", dependencies.responseErrors)
+	if err != nil {
+		return err
+	}
+
+	specV2, err := apiv2.GetSwagger()
+	if err != nil {
+		return err
+	}
+
+	validatorV2, err := middleware.OpenAPIValidator(specV2, "/api/v2", dependencies.responseErrors)
+	if err != nil {
+		return err
+	}
+
+	validators := []gin.HandlerFunc{validator, validatorV2}
+	if len(configuration.AdminTokens) > 0 {
+		specAdmin, err := apiadmin.GetSwagger()
+		if err != nil {
+			return err
+		}
+
+		validatorAdmin, err := middleware.OpenAPIValidator(specAdmin, "/admin", dependencies.responseErrors)
+		if err != nil {
+			return err
+		}
+		validators = append(validators, validatorAdmin)
+	}
+
+	limits := configuration.RateLimits
+	rateLimiter := middleware.RateLimit(dependencies.rateLimitStore,
+		middleware.RateLimitRule{Name: "subscribe", Method: http.MethodPost, Path: "/api/subscribe", Limit: limits.Subscribe},
+		middleware.RateLimitRule{Name: "subscribe-ip", Method: http.MethodPost, Path: "/api/subscribe", Limit: limits.SubscribeIp, Key: middleware.ClientIP},
+		middleware.RateLimitRule{Name: "subscribe-email", Method: http.MethodPost, Path: "/api/subscribe", Limit: limits.SubscribeEmail, Key: middleware.TargetEmail},
+		middleware.RateLimitRule{Name: "subscribe", Method: http.MethodPost, Path: "/api/v2/subscriptions", Limit: limits.Subscribe},
+		middleware.RateLimitRule{Name: "subscribe-ip", Method: http.MethodPost, Path: "/api/v2/subscriptions", Limit: limits.SubscribeIp, Key: middleware.ClientIP},
+		middleware.RateLimitRule{Name: "subscribe-email", Method: http.MethodPost, Path: "/api/v2/subscriptions", Limit: limits.SubscribeEmail, Key: middleware.TargetEmail},
+		middleware.RateLimitRule{Name: "weather", Method: http.MethodGet, Path: "/api/weather", Limit: limits.Weather},
+		middleware.RateLimitRule{Name: "weather-ip", Method: http.MethodGet, Path: "/api/weather", Limit: limits.WeatherIp, Key: middleware.ClientIP},
+	)
+
+	// validators wrap error handler to check problems it writes
+	router.Use(validators...)
+	router.Use(middleware.ErrorHandler(logger), middleware.CSRF(dependencies.formSecret, "error.html"))
+	router.LoadHTMLGlob("templates/*")
+	router.StaticFile("/favicon.ico", "./static/weather.ico")
+
+	router.GET("/", subscriptionController.Form)
+
+	parameterError := func(ctx *gin.Context, err error, status int) {
+		_ = ctx.Error(apperrors.Wrap(apperrors.Validation, "invalid request parameters", err))
+	}
+
+	apiGroup := router.Group("/api", middleware.APIKeys(dependencies.apiKeys, map[middleware.Route]string{
+		{Method: http.MethodGet, Path: "/api/weather"}:           models.ScopeWeatherRead,
+		{Method: http.MethodPost, Path: "/api/subscribe"}:        models.ScopeSubscriptionsWrite,
+		{Method: http.MethodPost, Path: "/api/v2/subscriptions"}: models.ScopeSubscriptionsWrite,
+	}), rateLimiter)
+
+	api.RegisterHandlersWithOptions(apiGroup, server, api.GinServerOptions{
+		ErrorHandler: parameterError,
+	})
+
+	// v1 is kept next to v2 for links in already sent letters
+	apiv2.RegisterHandlersWithOptions(apiGroup, subscriptionResourceController, apiv2.GinServerOptions{
+		BaseURL:      "/v2",
+		ErrorHandler: parameterError,
+	})
+
+	if len(configuration.AdminTokens) > 0 {
+		admin := router.Group("/admin", middleware.AdminAuth(configuration.AdminTokens))
+		apiadmin.RegisterHandlersWithOptions(admin, controllers.NewAdminController(dependencies.admin, logger), apiadmin.GinServerOptions{
+			ErrorHandler: parameterError,
+		})
+
+		// browsers can not send bearer tokens, so dashboard takes the same ones as basic auth passwords
+		dashboardController := controllers.NewDashboardController(dependencies.dashboard)
+		router.GET("/dashboard", gin.BasicAuthForRealm(configuration.AdminTokens, "dashboard"), dashboardController.Show)
+	}
+
+	return nil
+}
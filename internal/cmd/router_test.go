@@ -0,0 +1,378 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/antispam"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	// router loads pages from `templates`, relative to repository root
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type (
+	stubWeather struct{}
+
+	// stubSubscriptions records subscriptions it was asked to create,
+	// ones for emails of unconfirmed already exist.
+	stubSubscriptions struct {
+		mu          sync.Mutex
+		subscribed  []models.Subscription
+		unconfirmed map[string]bool
+	}
+
+	stubSubscriptionData struct{}
+
+	// stubApiKeys accepts keys it holds.
+	stubApiKeys map[string]models.ApiKey
+
+	// acceptingGuard lets every form submission through.
+	acceptingGuard struct{}
+)
+
+func (stubWeather) GetWeather(city string, _ context.Context) (models.Weather, error) {
+	return models.Weather{Temperature: 21.5, Humidity: 40, Description: "Sunny"}, nil
+}
+
+func (s *stubSubscriptions) Subscribe(subscription models.Subscription, _ context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribed = append(s.subscribed, subscription)
+	return !s.unconfirmed[subscription.Email], nil
+}
+
+func (s *stubSubscriptions) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subscribed)
+}
+
+func (s *stubSubscriptions) Confirm(uuid.UUID, context.Context) error     { return nil }
+func (s *stubSubscriptions) Unsubscribe(uuid.UUID, context.Context) error { return nil }
+func (s *stubSubscriptions) Authorize(uint, uuid.UUID, context.Context) error {
+	return nil
+}
+func (s *stubSubscriptions) ConfirmSubscription(uint, uuid.UUID, context.Context) error {
+	return nil
+}
+func (s *stubSubscriptions) ChangeSubscription(id uint, city string, frequency string, _ context.Context) (models.Subscription, error) {
+	subscription := models.Subscription{ID: id, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyDaily, Confirmed: true}
+	if city != "" {
+		subscription.City = city
+	}
+	if frequency != "" {
+		subscription.Frequency = frequency
+	}
+	return subscription, nil
+}
+
+func (stubSubscriptionData) GetSubscriptionById(id uint, _ context.Context) (models.Subscription, error) {
+	return models.Subscription{ID: id, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyDaily, Confirmed: true}, nil
+}
+func (stubSubscriptionData) GetSubscriptionByEmail(email string, _ context.Context) (*models.Subscription, error) {
+	return &models.Subscription{ID: 1, Email: email, City: "Kyiv", Frequency: models.FrequencyDaily, CreatedAt: time.Now()}, nil
+}
+func (stubSubscriptionData) DeleteSubscription(uint, context.Context) error { return nil }
+
+func (s stubApiKeys) Authenticate(raw string, _ context.Context) (models.ApiKey, error) {
+	key, ok := s[raw]
+	if !ok {
+		return models.ApiKey{}, apperrors.New(apperrors.Unauthorized, "API key is not valid")
+	}
+	return key, nil
+}
+
+func (acceptingGuard) NewForm(string) antispam.Form { return antispam.Form{} }
+func (acceptingGuard) Check(url.Values, string, string, context.Context) error {
+	return nil
+}
+func (acceptingGuard) CheckCaptcha(string, string, context.Context) error {
+	return nil
+}
+
+// testConfiguration is valid configuration with rate limits off, tests
+// enable ones they check.
+func testConfiguration() *config.Configuration {
+	configuration := config.Default()
+	configuration.BaseUrl = "http://localhost:8000"
+	configuration.RateLimits = config.RateLimits{Store: config.RateLimitMemory}
+	return &configuration
+}
+
+// testDependencies are stubs API is served with in tests.
+func testDependencies(subscriptions *stubSubscriptions) apiDependencies {
+	return apiDependencies{
+		weather:          stubWeather{},
+		subscriptions:    subscriptions,
+		subscriptionData: stubSubscriptionData{},
+		apiKeys:          stubApiKeys{},
+		botGuard:         acceptingGuard{},
+		rateLimitStore:   ratelimit.NewMemoryStore(),
+		formSecret:       []byte("test form secret"),
+	}
+}
+
+// newTestRouter builds router the way serve command does.
+func newTestRouter(t *testing.T, configuration *config.Configuration, dependencies apiDependencies) *gin.Engine {
+	t.Helper()
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	if err := registerApi(router, dependencies, configuration, testLogger); err != nil {
+		t.Fatal(err)
+	}
+
+	return router
+}
+
+var csrfInput = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// formSession opens subscription form like browser, returning CSRF cookie
+// and token form carries.
+func formSession(t *testing.T, router http.Handler) (*http.Cookie, string) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("form responded with %d", recorder.Code)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range recorder.Result().Cookies() {
+		if c.Name == "csrf" {
+			cookie = c
+		}
+	}
+	match := csrfInput.FindStringSubmatch(recorder.Body.String())
+	if cookie == nil || match == nil {
+		t.Fatal("form has no CSRF cookie or token")
+	}
+
+	return cookie, match[1]
+}
+
+func postForm(router http.Handler, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", gin.MIMEPOSTForm)
+	request.Header.Set("Accept", gin.MIMEHTML)
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func postJSON(router http.Handler, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", gin.MIMEJSON)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// Form posts pass CSRF, which parses body, before rate limits read email
+// from it, so per-email limit has to see parsed form.
+func TestSubscriptionFormIsLimitedPerEmail(t *testing.T) {
+	configuration := testConfiguration()
+	configuration.RateLimits.SubscribeEmail = ratelimit.Limit{Requests: 2, Window: time.Hour}
+	subscriptions := &stubSubscriptions{}
+	router := newTestRouter(t, configuration, testDependencies(subscriptions))
+
+	cookie, token := formSession(t, router)
+	form := url.Values{
+		"email":      {"target@example.com"},
+		"city":       {"Kyiv"},
+		"frequency":  {models.FrequencyDaily},
+		"issued":     {"signed"},
+		"csrf_token": {token},
+	}
+
+	for i := 1; i <= 2; i++ {
+		if recorder := postForm(router, "/api/subscribe", form, cookie); recorder.Code != http.StatusOK {
+			t.Fatalf("post %d responded with %d: %s", i, recorder.Code, recorder.Body)
+		}
+	}
+
+	// the same address written differently is the same target
+	form.Set("email", "Target@Example.com")
+	recorder := postForm(router, "/api/subscribe", form, cookie)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("post over limit responded with %d", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Error("limited response has no Retry-After")
+	}
+	if subscriptions.count() != 2 {
+		t.Errorf("%d subscriptions were created, want 2", subscriptions.count())
+	}
+
+	// other addresses are not affected
+	form.Set("email", "other@example.com")
+	if recorder := postForm(router, "/api/subscribe", form, cookie); recorder.Code != http.StatusOK {
+		t.Fatalf("post for other email responded with %d", recorder.Code)
+	}
+}
+
+func TestSubscriptionFormWithoutCSRFTokenIsRejected(t *testing.T) {
+	subscriptions := &stubSubscriptions{}
+	router := newTestRouter(t, testConfiguration(), testDependencies(subscriptions))
+
+	cookie, _ := formSession(t, router)
+	form := url.Values{
+		"email":     {"target@example.com"},
+		"city":      {"Kyiv"},
+		"frequency": {models.FrequencyDaily},
+		"issued":    {"signed"},
+	}
+
+	if recorder := postForm(router, "/api/subscribe", form, cookie); recorder.Code != http.StatusForbidden {
+		t.Fatalf("post without token responded with %d", recorder.Code)
+	}
+	if subscriptions.count() != 0 {
+		t.Error("subscription was created without CSRF token")
+	}
+}
+
+// JSON clients render no form, so anonymous ones are asked for CAPTCHA in
+// header, while clients with API key are trusted by its scope.
+func TestJSONSubscriptionNeedsCaptcha(t *testing.T) {
+	routes := []struct {
+		path   string
+		status int
+	}{
+		{"/api/subscribe", http.StatusOK},
+		{"/api/v2/subscriptions", http.StatusCreated},
+	}
+
+	for _, route := range routes {
+		t.Run(route.path, func(t *testing.T) {
+			subscriptions := &stubSubscriptions{}
+			dependencies := testDependencies(subscriptions)
+			dependencies.botGuard = antispam.NewGuard(dependencies.formSecret, antispam.NewFakeCaptcha("solved"))
+			dependencies.apiKeys = stubApiKeys{"partner-key": {Name: "partner", Scopes: models.ScopeSubscriptionsWrite}}
+			router := newTestRouter(t, testConfiguration(), dependencies)
+
+			body := `{"email": "user@example.com", "city": "Kyiv", "frequency": "daily"}`
+			tests := []struct {
+				name    string
+				headers map[string]string
+				status  int
+			}{
+				{"without CAPTCHA", nil, http.StatusBadRequest},
+				{"with wrong CAPTCHA", map[string]string{antispam.CaptchaHeader: "guessed"}, http.StatusBadRequest},
+				{"with solved CAPTCHA", map[string]string{antispam.CaptchaHeader: "solved"}, route.status},
+				{"with API key", map[string]string{"X-API-Key": "partner-key"}, route.status},
+			}
+
+			created := 0
+			for _, test := range tests {
+				recorder := postJSON(router, route.path, body, test.headers)
+				if recorder.Code != test.status {
+					t.Errorf("%s: responded with %d, want %d: %s", test.name, recorder.Code, test.status, recorder.Body)
+				}
+				if recorder.Code < 300 {
+					created++
+				}
+			}
+
+			if subscriptions.count() != created {
+				t.Errorf("%d subscriptions were created, want %d", subscriptions.count(), created)
+			}
+		})
+	}
+}
+
+// Without CAPTCHA provider nothing tells anonymous JSON client from bot, so
+// it has to use the form, which is checked by honeypot and signed time, or
+// API key.
+func TestJSONSubscriptionWithoutCaptchaProvider(t *testing.T) {
+	subscriptions := &stubSubscriptions{}
+	dependencies := testDependencies(subscriptions)
+	dependencies.botGuard = antispam.NewGuard(dependencies.formSecret, nil)
+	dependencies.apiKeys = stubApiKeys{"partner-key": {Name: "partner", Scopes: models.ScopeSubscriptionsWrite}}
+	router := newTestRouter(t, testConfiguration(), dependencies)
+
+	body := `{"email": "user@example.com", "city": "Kyiv", "frequency": "daily"}`
+	tests := []struct {
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{"/api/subscribe", nil, http.StatusForbidden},
+		{"/api/v2/subscriptions", nil, http.StatusForbidden},
+		{"/api/v2/subscriptions", map[string]string{antispam.CaptchaHeader: "solved"}, http.StatusForbidden},
+		{"/api/subscribe", map[string]string{"X-API-Key": "partner-key"}, http.StatusOK},
+		{"/api/v2/subscriptions", map[string]string{"X-API-Key": "partner-key"}, http.StatusCreated},
+	}
+
+	for _, test := range tests {
+		if recorder := postJSON(router, test.path, body, test.headers); recorder.Code != test.status {
+			t.Errorf("%s with %v: responded with %d, want %d: %s", test.path, test.headers, recorder.Code, test.status, recorder.Body)
+		}
+	}
+	if subscriptions.count() != 2 {
+		t.Errorf("%d subscriptions were created, want 2", subscriptions.count())
+	}
+
+}
+
+// Resource is created only once, posting email of unconfirmed subscription
+// again only resends its letter.
+func TestResubscribeIsAccepted(t *testing.T) {
+	subscriptions := &stubSubscriptions{unconfirmed: map[string]bool{"pending@example.com": true}}
+	router := newTestRouter(t, testConfiguration(), testDependencies(subscriptions))
+
+	tests := []struct {
+		email    string
+		status   int
+		location bool
+	}{
+		{"user@example.com", http.StatusCreated, true},
+		{"pending@example.com", http.StatusAccepted, false},
+	}
+
+	for _, test := range tests {
+		body := `{"email": "` + test.email + `", "city": "Kyiv", "frequency": "daily"}`
+		recorder := postJSON(router, "/api/v2/subscriptions", body, nil)
+		if recorder.Code != test.status {
+			t.Errorf("%s: responded with %d, want %d: %s", test.email, recorder.Code, test.status, recorder.Body)
+		}
+		if location := recorder.Header().Get("Location") != ""; location != test.location {
+			t.Errorf("%s: Location is %q", test.email, recorder.Header().Get("Location"))
+		}
+	}
+}
@@ -2,69 +2,264 @@ package config
 
 import (
 	"errors"
-	"log"
-	"os"
-	"strconv"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/Rabiann/weather-mailer/internal/ratelimit"
 )
 
+const (
+	RateLimitMemory   = "memory"
+	RateLimitPostgres = "postgres"
+)
+
+// Configuration is every setting of the application. Field tags tell where
+// setting is read from: `key` is its path in configuration file, `env` is
+// its environment variable. Flag of setting is its path in kebab case,
+// e.g. `--database-pool-max-open-conns`. Settings tagged `secret` are
+// redacted by Print, ones tagged `reload` are applied on reload without
+// restart.
 type Configuration struct {
-	BaseUrl           string
-	SendgridApiKey    string
-	WeatherApiKey     string
-	SenderMail        string
-	WeatherApiAddress string
-	Port              string
-	MailTimeout       int
-}
-
-func LoadEnvironment() (*Configuration, error) {
-	var config Configuration
-	var err error
-	if err = godotenv.Load(".env"); err != nil {
-		log.Fatal("`.env` is not found. Using user environment")
+	BaseUrl           string        `key:"baseUrl" env:"BASE_URL"`
+	SendgridApiKey    string        `key:"sendgridApiKey" env:"SENDGRID_API_KEY" secret:"true"`
+	SenderMail        string        `key:"senderMail" env:"SENDER_MAIL"`
+	MailTimeout       time.Duration `key:"mailTimeout" env:"MAIL_TIMEOUT" reload:"true"`
+	WeatherApiKey     string        `key:"weatherApiKey" env:"WEATHER_API_KEY" secret:"true"`
+	WeatherApiAddress string        `key:"weatherApiAddress" env:"WEATHER_API_ADDR"`
+	WeatherTimeout    time.Duration `key:"weatherTimeout" env:"WEATHER_TIMEOUT"`
+	// WeatherApiQuota is monthly request quota of weather provider plan,
+	// zero when unknown.
+	WeatherApiQuota int64 `key:"weatherApiQuota" env:"WEATHER_API_QUOTA"`
+
+	Server   Server   `key:"server"`
+	Database Database `key:"database"`
+	Notifier Notifier `key:"notifier"`
+
+	// AdminTokens maps operator name to its bearer token. Admin API is
+	// disabled when empty.
+	AdminTokens map[string]string `key:"adminTokens" env:"ADMIN_TOKENS" secret:"true"`
+	RateLimits  RateLimits        `key:"rateLimits"`
+	// TrustedProxies are addresses allowed to set `X-Forwarded-For`,
+	// client IP is taken from connection when empty.
+	TrustedProxies []string `key:"trustedProxies" env:"TRUSTED_PROXIES"`
+	// FormSecret signs data embedded into HTML forms. Random one is used
+	// when empty, so forms rendered before restart are rejected.
+	FormSecret string  `key:"formSecret" env:"FORM_SECRET" secret:"true"`
+	Captcha    Captcha `key:"captcha"`
+
+	// LogFormat is `text` or `json`.
+	LogFormat string     `key:"logFormat" env:"LOG_FORMAT"`
+	LogLevel  slog.Level `key:"logLevel" env:"LOG_LEVEL"`
+	// TraceExporter is where spans are sent, `otlp` or `stdout`. Tracing
+	// is disabled when empty.
+	TraceExporter string `key:"traceExporter" env:"TRACE_EXPORTER"`
+
+	// sources tells where settings which are not default came from.
+	sources map[string]string
+}
+
+type Server struct {
+	Port              int           `key:"port" env:"PORT"`
+	ReadHeaderTimeout time.Duration `key:"readHeaderTimeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	// ShutdownTimeout is how long requests in flight are waited for on shutdown.
+	ShutdownTimeout time.Duration `key:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+}
+
+// Database is where Postgres is. Url, when set, is used instead of
+// separate settings.
+type Database struct {
+	Url      string `key:"url" env:"DATABASE_URL" secret:"true"`
+	Host     string `key:"host" env:"POSTGRES_HOST"`
+	Port     int    `key:"port" env:"POSTGRES_PORT"`
+	User     string `key:"user" env:"POSTGRES_USER"`
+	Password string `key:"password" env:"POSTGRES_PASSWORD" secret:"true"`
+	Name     string `key:"name" env:"POSTGRES_DB"`
+	SslMode  string `key:"sslMode" env:"POSTGRES_SSLMODE"`
+	Pool     Pool   `key:"pool"`
+}
+
+// Pool limits connections kept to database, zero means no limit.
+type Pool struct {
+	MaxOpenConns    int           `key:"maxOpenConns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `key:"maxIdleConns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `key:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `key:"connMaxIdleTime" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+type Notifier struct {
+	// Concurrency is how many letters are prepared and sent at once.
+	Concurrency int `key:"concurrency" env:"NOTIFIER_CONCURRENCY" reload:"true"`
+}
+
+// Captcha configures CAPTCHA of subscription form. Provider is one of
+// `turnstile`, `hcaptcha`, `recaptcha` or `fake`, which accepts Secret as
+// response. CAPTCHA is disabled when Provider is empty.
+type Captcha struct {
+	Provider string `key:"provider" env:"CAPTCHA_PROVIDER"`
+	SiteKey  string `key:"siteKey" env:"CAPTCHA_SITE_KEY"`
+	Secret   string `key:"secret" env:"CAPTCHA_SECRET" secret:"true"`
+}
+
+// RateLimits are limits of public endpoints, zero limit disables one.
+// Store is where counters are kept, `memory` or `postgres`.
+type RateLimits struct {
+	Store          string          `key:"store" env:"RATE_LIMIT_STORE"`
+	Subscribe      ratelimit.Limit `key:"subscribe" env:"RATE_LIMIT_SUBSCRIBE"`
+	SubscribeIp    ratelimit.Limit `key:"subscribeIp" env:"RATE_LIMIT_SUBSCRIBE_IP"`
+	SubscribeEmail ratelimit.Limit `key:"subscribeEmail" env:"RATE_LIMIT_SUBSCRIBE_EMAIL"`
+	Weather        ratelimit.Limit `key:"weather" env:"RATE_LIMIT_WEATHER"`
+	WeatherIp      ratelimit.Limit `key:"weatherIp" env:"RATE_LIMIT_WEATHER_IP"`
+}
+
+// Default returns configuration every source is layered onto.
+func Default() Configuration {
+	return Configuration{
+		MailTimeout:    10 * time.Second,
+		WeatherTimeout: 10 * time.Second,
+		Server: Server{
+			Port:              8000,
+			ReadHeaderTimeout: 10 * time.Second,
+			ShutdownTimeout:   5 * time.Second,
+		},
+		Database: Database{
+			Host: "localhost",
+			Port: 5432,
+			Pool: Pool{
+				MaxOpenConns:    20,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: 30 * time.Minute,
+				ConnMaxIdleTime: 5 * time.Minute,
+			},
+		},
+		Notifier: Notifier{
+			Concurrency: 10,
+		},
+		AdminTokens: map[string]string{},
+		RateLimits: RateLimits{
+			Store:          RateLimitMemory,
+			SubscribeIp:    ratelimit.Limit{Requests: 10, Window: time.Hour},
+			SubscribeEmail: ratelimit.Limit{Requests: 3, Window: time.Hour},
+			Weather:        ratelimit.Limit{Requests: 500, Window: 24 * time.Hour},
+			WeatherIp:      ratelimit.Limit{Requests: 30, Window: time.Minute},
+		},
+		LogFormat: "text",
+		LogLevel:  slog.LevelInfo,
+	}
+}
+
+// validation collects every invalid setting.
+type validation struct {
+	errs []error
+}
+
+func (v *validation) invalid(err error) {
+	v.errs = append(v.errs, err)
+}
+
+func (v *validation) required(value string, name string) {
+	if value == "" {
+		v.invalid(fmt.Errorf("`%s` is not set", name))
 	}
+}
 
-	config.BaseUrl = os.Getenv("BASE_URL")
-	if config.BaseUrl == "" {
-		return nil, errors.New("`BASE_URL` is not set")
+func (v *validation) positive(value time.Duration, name string) {
+	if value <= 0 {
+		v.invalid(fmt.Errorf("`%s` should be positive duration", name))
 	}
+}
 
-	config.SendgridApiKey = os.Getenv("SENDGRID_API_KEY")
-	if config.BaseUrl == "" {
-		return nil, errors.New("`SENDGRID_API_KEY` is not set")
+func (v *validation) nonNegative(value int64, name string) {
+	if value < 0 {
+		v.invalid(fmt.Errorf("`%s` should be non-negative", name))
 	}
+}
 
-	config.WeatherApiKey = os.Getenv("WEATHER_API_KEY")
-	if config.BaseUrl == "" {
-		return nil, errors.New("`WEATHER_API_KEY` is not set")
+func (v *validation) oneOf(value string, name string, allowed ...string) {
+	for _, option := range allowed {
+		if value == option {
+			return
+		}
 	}
+	v.invalid(fmt.Errorf("`%s` should be one of %q", name, allowed))
+}
 
-	config.SenderMail = os.Getenv("SENDER_MAIL")
-	if config.SenderMail == "" {
-		return nil, errors.New("`SENDER_MAIL` is not set")
+func (v *validation) port(value int, name string) {
+	if value < 1 || value > 65535 {
+		v.invalid(fmt.Errorf("`%s` should be port between 1 and 65535", name))
 	}
+}
+
+func (v *validation) err() error {
+	return errors.Join(v.errs...)
+}
+
+// Validate reports every invalid setting at once.
+func (c *Configuration) Validate() error {
+	var v validation
 
-	config.WeatherApiAddress = os.Getenv("WEATHER_API_ADDR")
-	if config.WeatherApiAddress == "" {
-		return nil, errors.New("`WEATHER_API_ADDR` is not set")
+	v.required(c.BaseUrl, "BASE_URL")
+	if c.BaseUrl != "" {
+		// links of letters are dropped by html/template unless they have scheme
+		if base, err := url.Parse(c.BaseUrl); err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+			v.invalid(errors.New("`BASE_URL` should be absolute http or https URL, e.g. `http://localhost:8000`"))
+		}
 	}
+	v.required(c.SendgridApiKey, "SENDGRID_API_KEY")
+	v.required(c.SenderMail, "SENDER_MAIL")
+	v.required(c.WeatherApiKey, "WEATHER_API_KEY")
+	v.required(c.WeatherApiAddress, "WEATHER_API_ADDR")
+	v.positive(c.MailTimeout, "MAIL_TIMEOUT")
+	v.positive(c.WeatherTimeout, "WEATHER_TIMEOUT")
+	v.nonNegative(c.WeatherApiQuota, "WEATHER_API_QUOTA")
+
+	v.port(c.Server.Port, "PORT")
+	v.positive(c.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	v.positive(c.Server.ShutdownTimeout, "SERVER_SHUTDOWN_TIMEOUT")
 
-	mailTimeout := os.Getenv("MAIL_TIMEOUT")
-	if mailTimeout == "" {
-		return nil, errors.New("`MAIL_TIMEOUT` is not set")
+	c.Database.validate(&v)
+
+	if c.Notifier.Concurrency < 1 {
+		v.invalid(errors.New("`NOTIFIER_CONCURRENCY` should be at least 1"))
 	}
 
-	config.MailTimeout, err = strconv.Atoi(mailTimeout)
-	if err != nil {
-		return nil, errors.New("`MAIL_TIMEOUT` should be valid integer")
+	for name, token := range c.AdminTokens {
+		if name == "" || token == "" {
+			v.invalid(errors.New("`ADMIN_TOKENS` should be comma separated `name:token` pairs"))
+			break
+		}
 	}
 
-	config.Port = os.Getenv("PORT")
-	if config.Port == "" {
-		return nil, errors.New("`PORT` is not set")
+	v.oneOf(c.RateLimits.Store, "RATE_LIMIT_STORE", RateLimitMemory, RateLimitPostgres)
+
+	if c.Captcha.Provider != "" && c.Captcha.Secret == "" {
+		v.invalid(errors.New("`CAPTCHA_SECRET` is not set"))
 	}
 
-	return &config, nil
+	v.oneOf(c.LogFormat, "LOG_FORMAT", "text", "json")
+	v.oneOf(c.TraceExporter, "TRACE_EXPORTER", "", "otlp", "stdout")
+
+	return v.err()
+}
+
+// Validate reports every invalid database setting at once, for commands
+// which need nothing else.
+func (d Database) Validate() error {
+	var v validation
+	d.validate(&v)
+	return v.err()
+}
+
+func (d Database) validate(v *validation) {
+	if d.Url == "" {
+		v.required(d.Host, "POSTGRES_HOST")
+		v.required(d.User, "POSTGRES_USER")
+		v.required(d.Name, "POSTGRES_DB")
+		v.port(d.Port, "POSTGRES_PORT")
+	}
+	v.nonNegative(int64(d.Pool.MaxOpenConns), "DB_MAX_OPEN_CONNS")
+	v.nonNegative(int64(d.Pool.MaxIdleConns), "DB_MAX_IDLE_CONNS")
+	v.nonNegative(int64(d.Pool.ConnMaxLifetime), "DB_CONN_MAX_LIFETIME")
+	v.nonNegative(int64(d.Pool.ConnMaxIdleTime), "DB_CONN_MAX_IDLE_TIME")
 }
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// valid returns configuration with every required setting set.
+func valid() Configuration {
+	configuration := Default()
+	configuration.BaseUrl = "http://localhost:8000"
+	configuration.SendgridApiKey = "sendgrid-key"
+	configuration.SenderMail = "sender@example.com"
+	configuration.WeatherApiKey = "weather-key"
+	configuration.WeatherApiAddress = "http://api.weatherapi.com/v1/current.json?key=%s&q=%s&aqi=no"
+	configuration.Database.User = "user"
+	configuration.Database.Name = "subscriptions"
+
+	return configuration
+}
+
+func TestValidateAcceptsValidConfiguration(t *testing.T) {
+	configuration := valid()
+	if err := configuration.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateBaseUrl(t *testing.T) {
+	tests := []struct {
+		baseUrl string
+		valid   bool
+	}{
+		{"http://localhost:8000", true},
+		{"https://weather.example.com", true},
+		{"https://example.com/mailer", true},
+		{"localhost:8000", false},
+		{"weather.example.com", false},
+		{"/api", false},
+		{"ftp://example.com", false},
+		{"http://", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.baseUrl, func(t *testing.T) {
+			configuration := valid()
+			configuration.BaseUrl = test.baseUrl
+
+			err := configuration.Validate()
+			if test.valid && err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if !test.valid && (err == nil || !strings.Contains(err.Error(), "BASE_URL")) {
+				t.Fatalf("accepted, error: %v", err)
+			}
+		})
+	}
+}
+
+// `migrate` needs only database, so it should not ask for keys of mail and
+// weather providers.
+func TestDatabaseValidateIgnoresOtherSettings(t *testing.T) {
+	configuration := Default()
+	configuration.Database.User = "user"
+	configuration.Database.Name = "subscriptions"
+
+	if err := configuration.Database.Validate(); err != nil {
+		t.Fatalf("rejected: %v", err)
+	}
+	if err := configuration.Validate(); err == nil {
+		t.Fatal("configuration without providers accepted")
+	}
+
+	configuration.Database.User = ""
+	configuration.Database.Pool.MaxOpenConns = -1
+	err := configuration.Database.Validate()
+	if err == nil {
+		t.Fatal("invalid database accepted")
+	}
+	for _, name := range []string{"POSTGRES_USER", "DB_MAX_OPEN_CONNS"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("`%s` not reported, error: %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "SENDGRID_API_KEY") {
+		t.Errorf("unrelated setting reported: %v", err)
+	}
+}
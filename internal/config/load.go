@@ -0,0 +1,508 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFileEnv names configuration file when `--config` flag is not given.
+	ConfigFileEnv = "CONFIG_FILE"
+	// SecretsFileEnv names encrypted secrets file when `--secrets` flag is
+	// not given.
+	SecretsFileEnv = "SECRETS_FILE"
+	// SecretsKeyEnv is key secrets file is encrypted with.
+	SecretsKeyEnv = "SECRETS_KEY"
+	// FileSuffix turns variable into one naming file its value is read
+	// from, as Docker and Kubernetes mount secrets.
+	FileSuffix = "_FILE"
+)
+
+// Sources of settings, as shown by Print.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceSecrets = "secrets file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// setting is one leaf field of Configuration with names it is read by.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// Load reads configuration and validates it. Every invalid setting is
+// reported in returned error.
+func Load(args []string) (*Configuration, []string, error) {
+	return LoadWithFlags(newFlagSet(), args)
+}
+
+// LoadWithFlags is Load which parses args with flags, so that command can
+// take its own flags next to ones of settings.
+func LoadWithFlags(flags *flag.FlagSet, args []string) (*Configuration, []string, error) {
+	return load(flags, args, (*Configuration).Validate)
+}
+
+// LoadDatabase is Load which validates only database settings, for
+// commands which need nothing else.
+func LoadDatabase(args []string) (*Configuration, []string, error) {
+	return load(newFlagSet(), args, func(c *Configuration) error {
+		return c.Database.Validate()
+	})
+}
+
+func load(flags *flag.FlagSet, args []string, validate func(*Configuration) error) (*Configuration, []string, error) {
+	configuration, rest, err := ReadWithFlags(flags, args)
+	if configuration == nil {
+		return nil, nil, err
+	}
+
+	errs := []error{err}
+	if err := validate(configuration); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return configuration, rest, nil
+}
+
+// Read builds configuration from defaults, configuration file, encrypted
+// secrets file, `.env`, environment and flags in args, each later source
+// overriding earlier ones. It returns args left after flags. Configuration
+// is returned together with error about settings which could not be
+// parsed, it is nil only when sources could not be read at all.
+func Read(args []string) (*Configuration, []string, error) {
+	return ReadWithFlags(newFlagSet(), args)
+}
+
+// ReadWithFlags is Read which parses args with flags.
+func ReadWithFlags(flags *flag.FlagSet, args []string) (*Configuration, []string, error) {
+	configuration := Default()
+	configuration.sources = make(map[string]string)
+	settings := settingsOf(&configuration)
+
+	configFile := flags.String("config", "", "YAML or TOML configuration `file`, `"+ConfigFileEnv+"` when not given")
+	secretsFile := flags.String("secrets", "", "encrypted secrets `file`, `"+SecretsFileEnv+"` when not given")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		key := s.key
+		flags.Func(s.flag, "overrides `"+s.env+"`", func(raw string) error {
+			flagValues[key] = raw
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	vars, err := readVariables()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	if *configFile == "" {
+		*configFile, _ = vars.lookup(ConfigFileEnv)
+	}
+	if *configFile != "" {
+		fileValues, err := readFile(*configFile, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		errs = append(errs, configuration.apply(settings, fileValues, SourceFile, func(s setting) string { return "`" + s.key + "` in " + *configFile })...)
+	}
+
+	if *secretsFile == "" {
+		*secretsFile, _ = vars.lookup(SecretsFileEnv)
+	}
+	if *secretsFile != "" {
+		secretValues, err := readSecretsFile(*secretsFile, settings, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+		errs = append(errs, configuration.apply(settings, secretValues, SourceSecrets, func(s setting) string { return "`" + s.key + "` in " + *secretsFile })...)
+	}
+
+	envValues, envErrs := environment(settings, vars)
+	errs = append(errs, envErrs...)
+	errs = append(errs, configuration.apply(settings, envValues, SourceEnv, func(s setting) string { return "`" + s.env + "`" })...)
+	errs = append(errs, configuration.apply(settings, flagValues, SourceFlag, func(s setting) string { return "`--" + s.flag + "`" })...)
+
+	return &configuration, flags.Args(), errors.Join(errs...)
+}
+
+func newFlagSet() *flag.FlagSet {
+	return flag.NewFlagSet("weather-mailer", flag.ContinueOnError)
+}
+
+// RestartRequired lists keys of settings which differ in next but are not
+// applied on reload, so take effect only after restart.
+func (c *Configuration) RestartRequired(next *Configuration) []string {
+	current, changed := settingsOf(c), settingsOf(next)
+
+	var keys []string
+	for i, s := range current {
+		if !s.reload && !reflect.DeepEqual(s.value.Interface(), changed[i].value.Interface()) {
+			keys = append(keys, s.key)
+		}
+	}
+
+	return keys
+}
+
+// settingsOf lists leaf fields of configuration in declaration order.
+func settingsOf(configuration *Configuration) []setting {
+	var settings []setting
+	var walk func(value reflect.Value, prefix string)
+	walk = func(value reflect.Value, prefix string) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key := field.Tag.Get("key")
+			if key == "" {
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+
+			env := field.Tag.Get("env")
+			if env == "" && field.Type.Kind() == reflect.Struct {
+				walk(value.Field(i), key)
+				continue
+			}
+
+			settings = append(settings, setting{
+				key:    key,
+				env:    env,
+				flag:   flagName(key),
+				secret: field.Tag.Get("secret") == "true",
+				reload: field.Tag.Get("reload") == "true",
+				value:  value.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(configuration).Elem(), "")
+
+	return settings
+}
+
+// flagName turns `database.pool.maxOpenConns` into `database-pool-max-open-conns`.
+func flagName(key string) string {
+	var name strings.Builder
+	for _, r := range key {
+		switch {
+		case r == '.':
+			name.WriteRune('-')
+		case unicode.IsUpper(r):
+			name.WriteRune('-')
+			name.WriteRune(unicode.ToLower(r))
+		default:
+			name.WriteRune(r)
+		}
+	}
+
+	return name.String()
+}
+
+func environment(settings []setting, vars variables) (map[string]string, []error) {
+	values := make(map[string]string)
+	var errs []error
+
+	// deployments made before DATABASE_URL selected URL with PROD flag
+	if prod, _ := vars.lookup("PROD"); prod == "1" {
+		if raw, ok, err := vars.value("PROD_DB_URL"); err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			values["database.url"] = raw
+		}
+	}
+
+	for _, s := range settings {
+		raw, ok, err := vars.value(s.env)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			values[s.key] = raw
+		}
+	}
+
+	return values, errs
+}
+
+// variables are environment with `.env` under it. `.env` is a convenience
+// of local runs, deployments set environment. It is read anew by every
+// Read, so that reload picks up its changes.
+type variables map[string]string
+
+func readVariables() (variables, error) {
+	values, err := godotenv.Read(".env")
+	if errors.Is(err, fs.ErrNotExist) {
+		return variables{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("`.env`: %w", err)
+	}
+
+	return values, nil
+}
+
+// lookup reads variable from environment, or from `.env` when it is not set.
+func (v variables) lookup(name string) (string, bool) {
+	if raw, ok := os.LookupEnv(name); ok {
+		return raw, true
+	}
+
+	raw, ok := v[name]
+	return raw, ok
+}
+
+// value reads variable name, or file named by `<name>_FILE`. Either of them
+// set in environment hides both in `.env`.
+func (v variables) value(name string) (string, bool, error) {
+	_, inEnv := os.LookupEnv(name)
+	_, fileInEnv := os.LookupEnv(name + FileSuffix)
+	if inEnv || fileInEnv {
+		return lookupValue(os.LookupEnv, name)
+	}
+
+	return lookupValue(func(name string) (string, bool) {
+		raw, ok := v[name]
+		return raw, ok
+	}, name)
+}
+
+// LookupEnv reads variable name, or file named by `<name>_FILE`, from
+// environment or `.env`.
+func LookupEnv(name string) (string, bool, error) {
+	vars, err := readVariables()
+	if err != nil {
+		return "", false, err
+	}
+
+	return vars.value(name)
+}
+
+func lookupValue(lookup func(string) (string, bool), name string) (string, bool, error) {
+	raw, ok := lookup(name)
+	path, fromFile := lookup(name + FileSuffix)
+	if !fromFile {
+		return raw, ok, nil
+	}
+
+	if ok {
+		return "", false, fmt.Errorf("only one of `%s` and `%s` should be set", name, name+FileSuffix)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("`%s`: %w", name+FileSuffix, err)
+	}
+
+	// files written by editors and `echo` end with newline
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// apply sets settings found in values, remembering where they came from
+// and describing source of invalid ones with describe.
+func (c *Configuration) apply(settings []setting, values map[string]string, source string, describe func(setting) string) []error {
+	var errs []error
+	for _, s := range settings {
+		raw, ok := values[s.key]
+		if !ok {
+			continue
+		}
+
+		if err := set(s.value, strings.TrimSpace(raw)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", describe(s), err))
+			continue
+		}
+		c.sources[s.key] = source
+	}
+
+	return errs
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw into value. Durations are written as `1m30s`, bare
+// number means seconds. Lists are comma separated, maps are comma
+// separated `key:value` pairs.
+func set(value reflect.Value, raw string) error {
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	if value.Type() == durationType {
+		duration, err := parseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		number, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("`%s` is not integer", raw)
+		}
+		value.SetInt(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("`%s` is not boolean", raw)
+		}
+		value.SetBool(flag)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		pairs := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, item, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || key == "" || item == "" {
+				return errors.New("should be comma separated `key:value` pairs")
+			}
+			pairs[key] = item
+		}
+		value.Set(reflect.ValueOf(pairs))
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+
+	return nil
+}
+
+func parseDuration(raw string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("`%s` is not duration like `30s` or `1m30s`", raw)
+	}
+
+	return duration, nil
+}
+
+// readFile reads YAML or TOML file into values of settings keyed by their
+// paths. Unknown keys are reported, as they are mostly typos.
+func readFile(path string, settings []setting) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".toml":
+		err = toml.Unmarshal(content, &tree)
+	default:
+		return nil, fmt.Errorf("configuration file `%s` should be `.yaml`, `.yml` or `.toml`", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("configuration file `%s`: %w", path, err)
+	}
+
+	return flatten(tree, path, settings)
+}
+
+// flatten turns tree of file into values of settings keyed by their paths.
+func flatten(tree map[string]any, path string, settings []setting) (map[string]string, error) {
+	leaves := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		leaves[s.key] = true
+	}
+
+	values := make(map[string]string)
+	var unknown []string
+	var walk func(node map[string]any, prefix string)
+	walk = func(node map[string]any, prefix string) {
+		for name, child := range node {
+			key := name
+			if prefix != "" {
+				key = prefix + "." + name
+			}
+
+			if leaves[key] {
+				values[key] = scalar(child)
+			} else if nested, ok := child.(map[string]any); ok {
+				walk(nested, key)
+			} else {
+				unknown = append(unknown, key)
+			}
+		}
+	}
+	walk(tree, "")
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("`%s` has unknown settings: %s", path, strings.Join(unknown, ", "))
+	}
+
+	return values, nil
+}
+
+// scalar writes value of file in the form set reads: lists are joined with
+// commas and maps become `key:value` pairs.
+func scalar(node any) string {
+	switch value := node.(type) {
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, scalar(item))
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		pairs := make([]string, 0, len(value))
+		for key, item := range value {
+			pairs = append(pairs, key+":"+scalar(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
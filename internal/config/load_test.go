@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// chdir makes dir working directory for the rest of test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(previous); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// unsetenv removes variable for the rest of test.
+func unsetenv(t *testing.T, name string) {
+	t.Helper()
+
+	// Setenv restores previous value when test ends
+	t.Setenv(name, "")
+	if err := os.Unsetenv(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reload reads configuration again on SIGHUP, so changes of `.env` should
+// reach every Read, not only the first one.
+func TestReadPicksUpChangedDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	unsetenv(t, "MAIL_TIMEOUT")
+	unsetenv(t, "MAIL_TIMEOUT"+FileSuffix)
+
+	dotEnv := filepath.Join(dir, ".env")
+	steps := []struct {
+		dotEnv string
+		env    string
+		want   time.Duration
+	}{
+		{dotEnv: "MAIL_TIMEOUT=5s\n", want: 5 * time.Second},
+		{dotEnv: "MAIL_TIMEOUT=7s\n", want: 7 * time.Second},
+		// environment of process overrides `.env`
+		{dotEnv: "MAIL_TIMEOUT=7s\n", env: "9s", want: 9 * time.Second},
+	}
+
+	for _, step := range steps {
+		writeFile(t, dotEnv, step.dotEnv)
+		if step.env != "" {
+			t.Setenv("MAIL_TIMEOUT", step.env)
+		}
+
+		configuration, _, err := Read(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if configuration.MailTimeout != step.want {
+			t.Fatalf("mail timeout is %s, want %s", configuration.MailTimeout, step.want)
+		}
+		if source := configuration.sources["mailTimeout"]; source != SourceEnv {
+			t.Errorf("mail timeout came from %q", source)
+		}
+	}
+}
+
+// Variable set in environment hides its `_FILE` form in `.env`, so they do
+// not conflict.
+func TestEnvironmentHidesDotEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	writeFile(t, filepath.Join(dir, "timeout"), "5s\n")
+	writeFile(t, filepath.Join(dir, ".env"), "MAIL_TIMEOUT_FILE=timeout\n")
+	t.Setenv("MAIL_TIMEOUT", "9s")
+
+	configuration, _, err := Read(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configuration.MailTimeout != 9*time.Second {
+		t.Fatalf("mail timeout is %s, want 9s", configuration.MailTimeout)
+	}
+
+	unsetenv(t, "MAIL_TIMEOUT")
+	configuration, _, err = Read(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configuration.MailTimeout != 5*time.Second {
+		t.Fatalf("mail timeout from `.env` file is %s, want 5s", configuration.MailTimeout)
+	}
+}
+
+// Every source overrides ones before it: file, secrets file, environment,
+// flags.
+func TestSourcesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	for _, name := range []string{"NOTIFIER_CONCURRENCY", "SENDGRID_API_KEY", ConfigFileEnv, SecretsFileEnv} {
+		unsetenv(t, name)
+		unsetenv(t, name+FileSuffix)
+	}
+
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	t.Setenv(SecretsKeyEnv, base64.StdEncoding.EncodeToString(key))
+
+	configFile := filepath.Join(dir, "config.yaml")
+	writeFile(t, configFile, "sendgridApiKey: from-file\nnotifier:\n  concurrency: 1\n")
+
+	secrets, err := EncryptSecrets([]byte("sendgridApiKey: from-secrets\nnotifier:\n  concurrency: 2\n"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretsFile := filepath.Join(dir, "secrets.enc")
+	writeFile(t, secretsFile, string(secrets))
+
+	tests := []struct {
+		name        string
+		file        bool
+		secrets     bool
+		env         bool
+		flags       bool
+		concurrency int
+		apiKey      string
+		source      string
+	}{
+		{name: "defaults", concurrency: 10, source: ""},
+		{name: "file", file: true, concurrency: 1, apiKey: "from-file", source: SourceFile},
+		{name: "secrets over file", file: true, secrets: true, concurrency: 2, apiKey: "from-secrets", source: SourceSecrets},
+		{name: "env over secrets", file: true, secrets: true, env: true, concurrency: 3, apiKey: "from-env", source: SourceEnv},
+		{name: "flags over env", file: true, secrets: true, env: true, flags: true, concurrency: 4, apiKey: "from-flag", source: SourceFlag},
+		{name: "flags over file", file: true, flags: true, concurrency: 4, apiKey: "from-flag", source: SourceFlag},
+		{name: "env over file", file: true, env: true, concurrency: 3, apiKey: "from-env", source: SourceEnv},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var args []string
+			if test.file {
+				args = append(args, "--config", configFile)
+			}
+			if test.secrets {
+				args = append(args, "--secrets", secretsFile)
+			}
+			if test.env {
+				t.Setenv("NOTIFIER_CONCURRENCY", "3")
+				t.Setenv("SENDGRID_API_KEY", "from-env")
+			} else {
+				unsetenv(t, "NOTIFIER_CONCURRENCY")
+				unsetenv(t, "SENDGRID_API_KEY")
+			}
+			if test.flags {
+				args = append(args, "--notifier-concurrency", "4", "--sendgrid-api-key", "from-flag")
+			}
+
+			configuration, _, err := Read(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if configuration.Notifier.Concurrency != test.concurrency || configuration.SendgridApiKey != test.apiKey {
+				t.Errorf("concurrency %d and API key %q, want %d and %q", configuration.Notifier.Concurrency, configuration.SendgridApiKey, test.concurrency, test.apiKey)
+			}
+			for _, key := range []string{"notifier.concurrency", "sendgridApiKey"} {
+				if source := configuration.sources[key]; source != test.source {
+					t.Errorf("`%s` came from %q, want %q", key, source, test.source)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces value of secret setting in Print output.
+const Redacted = "[redacted]"
+
+// Print writes configuration as YAML, which is valid configuration file,
+// with source of every setting in comments. Secrets which are set are
+// replaced with Redacted.
+func (c *Configuration) Print(w io.Writer) error {
+	var path []string
+	for _, s := range settingsOf(c) {
+		parts := strings.Split(s.key, ".")
+		parents, name := parts[:len(parts)-1], parts[len(parts)-1]
+
+		common := 0
+		for common < len(path) && common < len(parents) && path[common] == parents[common] {
+			common++
+		}
+		for i := common; i < len(parents); i++ {
+			if _, err := fmt.Fprintf(w, "%s%s:\n", strings.Repeat("  ", i), parents[i]); err != nil {
+				return err
+			}
+		}
+		path = parents
+
+		value := printable(s.value)
+		if s.secret && !s.value.IsZero() && (s.value.Kind() != reflect.Map || s.value.Len() > 0) {
+			value = Redacted
+		}
+
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "%s%s: %s # %s\n", strings.Repeat("  ", len(parents)), name, strings.TrimSpace(string(encoded)), c.source(s)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Configuration) source(s setting) string {
+	switch source := c.sources[s.key]; source {
+	case "":
+		return SourceDefault
+	case SourceEnv:
+		return SourceEnv + " " + s.env
+	case SourceFlag:
+		return SourceFlag + " --" + s.flag
+	default:
+		return source
+	}
+}
+
+// printable returns value in the form set reads back.
+func printable(value reflect.Value) any {
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+
+	if value.Type() == durationType {
+		return value.Interface().(fmt.Stringer).String()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		return strings.Join(value.Interface().([]string), ",")
+	case reflect.Map:
+		pairs := make([]string, 0, value.Len())
+		for key, item := range value.Interface().(map[string]string) {
+			pairs = append(pairs, key+":"+item)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return value.Interface()
+	}
+}
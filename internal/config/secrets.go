@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretsHeader starts encrypted secrets file, so that file of other kind
+// is told apart from one encrypted with wrong key.
+const secretsHeader = "weather-mailer secrets v1\n"
+
+var ErrSecretsKey = errors.New("`" + SecretsKeyEnv + "` should be base64 of 32 random bytes, e.g. from `openssl rand -base64 32`")
+
+// ParseSecretsKey reads AES-256 key written in base64.
+func ParseSecretsKey(raw string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(key) != 32 {
+		return nil, ErrSecretsKey
+	}
+
+	return key, nil
+}
+
+// EncryptSecrets seals YAML with settings using AES-GCM.
+func EncryptSecrets(plaintext []byte, key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(secretsHeader))
+	return []byte(secretsHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// DecryptSecrets opens file made by EncryptSecrets.
+func DecryptSecrets(content []byte, key []byte) ([]byte, error) {
+	encoded, ok := bytes.CutPrefix(content, []byte(secretsHeader))
+	if !ok {
+		return nil, errors.New("not an encrypted secrets file")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, errors.New("encrypted secrets file is corrupted")
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted secrets file is corrupted")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(secretsHeader))
+	if err != nil {
+		return nil, errors.New("encrypted secrets file can not be decrypted, key is wrong or file is corrupted")
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// readSecretsFile decrypts secrets file with key from `SECRETS_KEY` and
+// reads settings from YAML inside.
+func readSecretsFile(path string, settings []setting, vars variables) (map[string]string, error) {
+	rawKey, ok, err := vars.value(SecretsKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("`%s` is not set, secrets file `%s` can not be decrypted", SecretsKeyEnv, path)
+	}
+
+	key, err := ParseSecretsKey(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := DecryptSecrets(content, key)
+	if err != nil {
+		return nil, fmt.Errorf("`%s`: %w", path, err)
+	}
+
+	tree := make(map[string]any)
+	if err := yaml.Unmarshal(plaintext, &tree); err != nil {
+		return nil, fmt.Errorf("`%s`: %w", path, err)
+	}
+
+	return flatten(tree, path, settings)
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(seed byte) []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = seed + byte(i)
+	}
+	return key
+}
+
+func TestSecretsRoundTrip(t *testing.T) {
+	key := testKey(1)
+
+	for _, plaintext := range []string{"", "sendgridApiKey: key\n", strings.Repeat("adminTokens: a:b\n", 100)} {
+		sealed, err := EncryptSecrets([]byte(plaintext), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext != "" && bytes.Contains(sealed, []byte(plaintext)) {
+			t.Error("secrets are stored in plain text")
+		}
+
+		opened, err := DecryptSecrets(sealed, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(opened) != plaintext {
+			t.Errorf("decrypted %q, want %q", opened, plaintext)
+		}
+	}
+
+	// every encryption has nonce of its own
+	first, _ := EncryptSecrets([]byte("same"), key)
+	second, _ := EncryptSecrets([]byte("same"), key)
+	if bytes.Equal(first, second) {
+		t.Error("the same secrets are encrypted the same way twice")
+	}
+}
+
+func TestDecryptSecretsRejects(t *testing.T) {
+	key := testKey(1)
+	sealed, err := EncryptSecrets([]byte("sendgridApiKey: key\n"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flip one byte of ciphertext, keeping it valid base64
+	encoded := []byte(strings.TrimSpace(strings.TrimPrefix(string(sealed), secretsHeader)))
+	raw, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := secretsHeader + base64.StdEncoding.EncodeToString(raw) + "\n"
+
+	tests := []struct {
+		name    string
+		content string
+		key     []byte
+		want    string
+	}{
+		{"wrong key", string(sealed), testKey(2), "key is wrong or file is corrupted"},
+		{"tampered ciphertext", tampered, key, "key is wrong or file is corrupted"},
+		{"not base64", secretsHeader + "not base64!\n", key, "corrupted"},
+		{"too short", secretsHeader + base64.StdEncoding.EncodeToString([]byte("short")) + "\n", key, "corrupted"},
+		{"plain YAML", "sendgridApiKey: key\n", key, "not an encrypted secrets file"},
+		{"prefix of right key", string(sealed), key[:16], "key is wrong or file is corrupted"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plaintext, err := DecryptSecrets([]byte(test.content), test.key)
+			if err == nil {
+				t.Fatalf("decrypted as %q", plaintext)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %q, want it to mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretsKey(t *testing.T) {
+	tests := []struct {
+		raw   string
+		valid bool
+	}{
+		{base64.StdEncoding.EncodeToString(testKey(1)), true},
+		{base64.StdEncoding.EncodeToString(testKey(1)[:16]), false},
+		{"not base64!", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseSecretsKey(test.raw)
+		if test.valid != (err == nil) {
+			t.Errorf("%q: got %v", test.raw, err)
+		}
+		if err != nil && err != ErrSecretsKey {
+			t.Errorf("%q: got %v, want %v", test.raw, err, ErrSecretsKey)
+		}
+	}
+}
+
+// Secrets file is read with key from environment, also given as file.
+func TestReadSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	for _, name := range []string{"SENDGRID_API_KEY", SecretsKeyEnv, ConfigFileEnv, SecretsFileEnv} {
+		unsetenv(t, name)
+		unsetenv(t, name+FileSuffix)
+	}
+
+	key := testKey(1)
+	sealed, err := EncryptSecrets([]byte("sendgridApiKey: from-secrets\n"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretsFile := filepath.Join(dir, "secrets.enc")
+	writeFile(t, secretsFile, string(sealed))
+	keyFile := filepath.Join(dir, "secrets.key")
+	writeFile(t, keyFile, base64.StdEncoding.EncodeToString(key)+"\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "key in variable", env: map[string]string{SecretsKeyEnv: base64.StdEncoding.EncodeToString(key)}},
+		{name: "key in file", env: map[string]string{SecretsKeyEnv + FileSuffix: keyFile}},
+		{name: "key missing", want: "is not set"},
+		{name: "wrong key", env: map[string]string{SecretsKeyEnv: base64.StdEncoding.EncodeToString(testKey(2))}, want: "key is wrong"},
+		{name: "key not base64", env: map[string]string{SecretsKeyEnv: "not base64!"}, want: ErrSecretsKey.Error()},
+		{
+			name: "key in both variable and file",
+			env:  map[string]string{SecretsKeyEnv: base64.StdEncoding.EncodeToString(key), SecretsKeyEnv + FileSuffix: keyFile},
+			want: "only one of `" + SecretsKeyEnv + "` and `" + SecretsKeyEnv + FileSuffix + "`",
+		},
+		{name: "key file missing", env: map[string]string{SecretsKeyEnv + FileSuffix: filepath.Join(dir, "missing")}, want: SecretsKeyEnv + FileSuffix},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{SecretsKeyEnv, SecretsKeyEnv + FileSuffix} {
+				unsetenv(t, name)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			configuration, _, err := Read([]string{"--secrets", secretsFile})
+			if test.want != "" {
+				if err == nil || !strings.Contains(err.Error(), test.want) {
+					t.Fatalf("got %v, want error mentioning %q", err, test.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if configuration.SendgridApiKey != "from-secrets" {
+				t.Errorf("SendGrid API key is %q", configuration.SendgridApiKey)
+			}
+		})
+	}
+}
+
+func TestLookupEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	const name = "WEATHER_MAILER_TEST_VALUE"
+
+	valueFile := filepath.Join(dir, "value")
+	writeFile(t, valueFile, "from-file\r\n")
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		value string
+		found bool
+		err   string
+	}{
+		{name: "neither set"},
+		{name: "variable", env: map[string]string{name: "from-env"}, value: "from-env", found: true},
+		{name: "file without trailing newline", env: map[string]string{name + FileSuffix: valueFile}, value: "from-file", found: true},
+		{name: "both set", env: map[string]string{name: "from-env", name + FileSuffix: valueFile}, err: "only one of"},
+		{name: "file missing", env: map[string]string{name + FileSuffix: filepath.Join(dir, "missing")}, err: name + FileSuffix},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unsetenv(t, name)
+			unsetenv(t, name+FileSuffix)
+			for variable, value := range test.env {
+				t.Setenv(variable, value)
+			}
+
+			value, found, err := LookupEnv(name)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, want error mentioning %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.value || found != test.found {
+				t.Errorf("got %q, %t, want %q, %t", value, found, test.value, test.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	apiadmin "github.com/Rabiann/weather-mailer/internal/api/admin"
+	"github.com/Rabiann/weather-mailer/internal/middleware"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 50
+	// tokenHashPrefix is how many characters of token hash are shown to operators.
+	tokenHashPrefix = 12
+)
+
+type (
+	// AdminController serves `/admin` API for operators.
+	AdminController struct {
+		adminService AdminService
+		logger       *slog.Logger
+	}
+
+	AdminService interface {
+		SearchSubscriptions(models.SubscriptionFilter, context.Context) ([]models.Subscription, int64, error)
+		GetSubscriptionDetails(uint, context.Context) (models.SubscriptionDetails, error)
+		ConfirmSubscription(string, uint, context.Context) (models.Subscription, error)
+		DeactivateSubscription(string, uint, context.Context) (models.Subscription, error)
+		DeleteSubscription(string, uint, context.Context) error
+		GetAuditRecords(int, int, context.Context) ([]models.AuditRecord, int64, error)
+	}
+)
+
+var _ apiadmin.ServerInterface = (*AdminController)(nil)
+
+func NewAdminController(adminService AdminService, logger *slog.Logger) *AdminController {
+	return &AdminController{adminService, logger}
+}
+
+// logAction records change made by operator, audit table keeps the same
+// for good, log ties it to request.
+func (a *AdminController) logAction(ctx *gin.Context, action string, id apiadmin.SubscriptionId) {
+	a.logger.InfoContext(ctx, "admin action",
+		slog.String("actor", ctx.GetString(middleware.ActorKey)),
+		slog.String("action", action),
+		slog.Int("subscription_id", int(id)),
+	)
+}
+
+// pagination turns optional page parameters into page, page size and offset.
+func pagination(page *int, pageSize *int) (int, int, int) {
+	p, size := 1, defaultPageSize
+	if page != nil {
+		p = *page
+	}
+	if pageSize != nil {
+		size = *pageSize
+	}
+
+	return p, size, (p - 1) * size
+}
+
+func adminSubscription(subscription models.Subscription) apiadmin.Subscription {
+	return apiadmin.Subscription{
+		Id:        int(subscription.ID),
+		Email:     subscription.Email,
+		City:      subscription.City,
+		Frequency: subscription.Frequency,
+		Confirmed: subscription.Confirmed,
+		CreatedAt: subscription.CreatedAt,
+		UpdatedAt: subscription.UpdatedAt,
+	}
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (a *AdminController) ListSubscriptions(ctx *gin.Context, params apiadmin.ListSubscriptionsParams) {
+	page, pageSize, offset := pagination(params.Page, params.PageSize)
+	filter := models.SubscriptionFilter{
+		Confirmed:   params.Confirmed,
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+		Offset:      offset,
+		Limit:       pageSize,
+	}
+	if params.City != nil {
+		filter.City = strings.TrimSpace(*params.City)
+	}
+	if params.Frequency != nil {
+		filter.Frequency = string(*params.Frequency)
+	}
+
+	subscriptions, total, err := a.adminService.SearchSubscriptions(filter, ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	items := make([]apiadmin.Subscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		items = append(items, adminSubscription(subscription))
+	}
+
+	ctx.JSON(http.StatusOK, apiadmin.SubscriptionPage{
+		Items:    items,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    int(total),
+	})
+}
+
+func (a *AdminController) GetSubscriptionDetails(ctx *gin.Context, id apiadmin.SubscriptionId) {
+	details, err := a.adminService.GetSubscriptionDetails(uint(id), ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	tokens := make([]apiadmin.Token, 0, len(details.Tokens))
+	for _, token := range details.Tokens {
+		tokens = append(tokens, apiadmin.Token{
+			HashPrefix: token.Hash[:min(len(token.Hash), tokenHashPrefix)],
+			Expires:    token.Expires,
+			CreatedAt:  token.CreatedAt,
+		})
+	}
+
+	deliveries := make([]apiadmin.Delivery, 0, len(details.Deliveries))
+	for _, delivery := range details.Deliveries {
+		deliveries = append(deliveries, apiadmin.Delivery{
+			Kind:      delivery.Kind,
+			Status:    delivery.Status,
+			Error:     optional(delivery.Error),
+			CreatedAt: delivery.CreatedAt,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, apiadmin.SubscriptionDetails{
+		Subscription: adminSubscription(details.Subscription),
+		Tokens:       tokens,
+		Deliveries:   deliveries,
+	})
+}
+
+func (a *AdminController) ConfirmSubscription(ctx *gin.Context, id apiadmin.SubscriptionId) {
+	subscription, err := a.adminService.ConfirmSubscription(ctx.GetString(middleware.ActorKey), uint(id), ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	a.logAction(ctx, "confirm", id)
+
+	ctx.JSON(http.StatusOK, adminSubscription(subscription))
+}
+
+func (a *AdminController) DeactivateSubscription(ctx *gin.Context, id apiadmin.SubscriptionId) {
+	subscription, err := a.adminService.DeactivateSubscription(ctx.GetString(middleware.ActorKey), uint(id), ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	a.logAction(ctx, "deactivate", id)
+
+	ctx.JSON(http.StatusOK, adminSubscription(subscription))
+}
+
+func (a *AdminController) DeleteSubscription(ctx *gin.Context, id apiadmin.SubscriptionId) {
+	if err := a.adminService.DeleteSubscription(ctx.GetString(middleware.ActorKey), uint(id), ctx); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	a.logAction(ctx, "delete", id)
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (a *AdminController) ListAuditRecords(ctx *gin.Context, params apiadmin.ListAuditRecordsParams) {
+	page, pageSize, offset := pagination(params.Page, params.PageSize)
+
+	records, total, err := a.adminService.GetAuditRecords(offset, pageSize, ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	items := make([]apiadmin.AuditRecord, 0, len(records))
+	for _, record := range records {
+		item := apiadmin.AuditRecord{
+			Id:        int(record.ID),
+			Actor:     record.Actor,
+			Action:    record.Action,
+			Details:   optional(record.Details),
+			CreatedAt: record.CreatedAt,
+		}
+		if record.SubscriptionID != 0 {
+			subscriptionId := int(record.SubscriptionID)
+			item.SubscriptionId = &subscriptionId
+		}
+		items = append(items, item)
+	}
+
+	ctx.JSON(http.StatusOK, apiadmin.AuditPage{
+		Items:    items,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    int(total),
+	})
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Rabiann/weather-mailer/internal/middleware"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// DashboardController serves statistics page for support team.
+	DashboardController struct {
+		dashboardService DashboardService
+	}
+
+	DashboardService interface {
+		GetStatistics(context.Context) (models.Statistics, error)
+	}
+)
+
+func NewDashboardController(dashboardService DashboardService) DashboardController {
+	return DashboardController{dashboardService}
+}
+
+func (d DashboardController) Show(ctx *gin.Context) {
+	middleware.SetErrorPage(ctx, "error.html")
+
+	statistics, err := d.dashboardService.GetStatistics(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.HTML(http.StatusOK, "dashboard.html", gin.H{
+		"Operator":   ctx.GetString(gin.AuthUserKey),
+		"Statistics": statistics,
+	})
+}
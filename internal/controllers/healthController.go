@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// HealthController serves probes of orchestrator.
+	HealthController struct {
+		healthService HealthService
+	}
+
+	HealthService interface {
+		Check(context.Context) models.Health
+	}
+)
+
+func NewHealthController(healthService HealthService) HealthController {
+	return HealthController{healthService}
+}
+
+// Live tells that process serves requests, it checks nothing else, so that
+// orchestrator does not restart the application when dependency is down.
+func (h HealthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": models.HealthOk})
+}
+
+// Ready tells whether the application can do its work. Degraded one is
+// still ready.
+func (h HealthController) Ready(ctx *gin.Context) {
+	health := h.healthService.Check(ctx)
+
+	status := http.StatusOK
+	if health.Status == models.HealthFail {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.JSON(status, health)
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import "github.com/Rabiann/weather-mailer/internal/api"
+
+// Server implements handlers generated from api/openapi.yaml. Build fails
+// whenever controllers and specification diverge.
+type Server struct {
+	WeatherController
+	SubscriptionController
+}
+
+var _ api.ServerInterface = (*Server)(nil)
+
+func NewServer(weatherController WeatherController, subscriptionController SubscriptionController) *Server {
+	return &Server{weatherController, subscriptionController}
+}
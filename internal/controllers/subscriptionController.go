@@ -2,78 +2,149 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/Rabiann/weather-mailer/internal/antispam"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/middleware"
 	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"net/http"
 )
 
 type (
 	SubscriptionController struct {
 		SubscriptionService SubscriptionService
+		BotGuard            BotGuard
+		Logger              *slog.Logger
 	}
 
-	MailingService interface {
-		SendConfirmationLetter(string, string) error
+	subscriptionForm struct {
+		antispam.Form
+		CSRFToken string
 	}
 
-	TokenService interface {
-		CreateToken(uint, context.Context) (uuid.UUID, error)
-		GetSubscriptionOfToken(uuid.UUID, context.Context) (uint, error)
-		UseToken(uuid.UUID, context.Context) error
+	BotGuard interface {
+		NewForm(string) antispam.Form
+		Check(url.Values, string, string, context.Context) error
+		CheckCaptcha(string, string, context.Context) error
 	}
 
 	SubscriptionService interface {
-		Subscribe(models.Subscription, context.Context) error
+		Subscribe(models.Subscription, context.Context) (bool, error)
 		Confirm(uuid.UUID, context.Context) error
 		Unsubscribe(uuid.UUID, context.Context) error
 	}
 )
 
-func NewSubscriptionController(subscriptionService SubscriptionService) SubscriptionController {
-	return SubscriptionController{SubscriptionService: subscriptionService}
+func NewSubscriptionController(subscriptionService SubscriptionService, botGuard BotGuard, logger *slog.Logger) SubscriptionController {
+	return SubscriptionController{SubscriptionService: subscriptionService, BotGuard: botGuard, Logger: logger}
+}
+
+var ErrInvalidToken = apperrors.New(apperrors.Validation, "invalid token")
+
+// checkBot guards subscription of anonymous client: forms are checked
+// fully within session of their CSRF token, other content types need
+// CAPTCHA in `X-Captcha-Response` and are rejected when it is disabled.
+// Clients with API key are trusted by its scope and quota.
+func checkBot(ctx *gin.Context, botGuard BotGuard, logger *slog.Logger) error {
+	if _, ok := ctx.Get(middleware.ApiKeyKey); ok {
+		return nil
+	}
+
+	var err error
+	if ctx.ContentType() == gin.MIMEPOSTForm {
+		err = botGuard.Check(ctx.Request.PostForm, middleware.CSRFToken(ctx), ctx.ClientIP(), ctx)
+	} else {
+		err = botGuard.CheckCaptcha(ctx.GetHeader(antispam.CaptchaHeader), ctx.ClientIP(), ctx)
+	}
+
+	if err != nil {
+		logger.WarnContext(ctx, "subscription rejected as automated", slog.String("client_ip", ctx.ClientIP()), slog.String("content_type", ctx.ContentType()), slog.Any("error", err))
+	}
+
+	return err
+}
+
+// Form renders subscription form.
+func (s *SubscriptionController) Form(ctx *gin.Context) {
+	ctx.HTML(http.StatusOK, "subscriptions.html", subscriptionForm{
+		Form:      s.BotGuard.NewForm(middleware.CSRFToken(ctx)),
+		CSRFToken: middleware.CSRFToken(ctx),
+	})
 }
 
 func (s *SubscriptionController) Subscribe(ctx *gin.Context) {
+	middleware.SetErrorPage(ctx, "error.html")
+
 	var subscription models.Subscription
 	if err := ctx.ShouldBind(&subscription); err != nil {
-		ctx.JSON(400, gin.H{"status": "bad request"})
+		_ = ctx.Error(bindingError(err))
 		return
 	}
 
-	if err := s.SubscriptionService.Subscribe(subscription, ctx); err != nil {
-		ctx.JSON(400, gin.H{"status": "bad request"})
+	if err := checkBot(ctx, s.BotGuard, s.Logger); err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.HTML(http.StatusOK, "needconfirmation.html", gin.H{})
+	if _, err := s.SubscriptionService.Subscribe(subscription, ctx); err != nil {
+		switch {
+		case errors.Is(err, services.ErrAlreadySubscribed):
+			middleware.SetErrorPage(ctx, "alreadysubscribed.html")
+		case errors.Is(err, services.ErrConfirmationCooldown):
+			middleware.SetErrorPage(ctx, "needconfirmation.html")
+		}
+		_ = ctx.Error(err)
+		return
+	}
+
+	middleware.Respond(ctx, http.StatusOK, "needconfirmation.html", middleware.Result{
+		Status:  "confirmation_requested",
+		Message: "Confirmation letter was sent to your email",
+	})
 }
 
-func (s *SubscriptionController) Confirm(ctx *gin.Context) {
-	token, err := uuid.Parse(ctx.Param("token"))
+func (s *SubscriptionController) ConfirmSubscription(ctx *gin.Context, rawToken string) {
+	middleware.SetErrorPage(ctx, "registrationfailed.html")
+
+	token, err := uuid.Parse(rawToken)
 	if err != nil {
-		ctx.HTML(400, "registrationfailed.html", gin.H{})
+		_ = ctx.Error(ErrInvalidToken)
 		return
 	}
 
 	if err := s.SubscriptionService.Confirm(token, ctx); err != nil {
-		ctx.HTML(400, "registrationfailed.html", gin.H{})
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.HTML(http.StatusOK, "registration.html", gin.H{})
+	middleware.Respond(ctx, http.StatusOK, "registration.html", middleware.Result{
+		Status:  "confirmed",
+		Message: "Subscription confirmed",
+	})
 }
 
-func (s SubscriptionController) Unsubscribe(ctx *gin.Context) {
-	token, err := uuid.Parse(ctx.Param("token"))
+func (s SubscriptionController) Unsubscribe(ctx *gin.Context, rawToken string) {
+	middleware.SetErrorPage(ctx, "registrationfailed.html")
+
+	token, err := uuid.Parse(rawToken)
 	if err != nil {
-		ctx.HTML(400, "registrationfailed.html", gin.H{})
+		_ = ctx.Error(ErrInvalidToken)
 		return
 	}
+
 	if err := s.SubscriptionService.Unsubscribe(token, ctx); err != nil {
-		ctx.JSON(400, gin.H{"status": "invalid params"})
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.HTML(http.StatusOK, "unsubscription.html", gin.H{})
+	middleware.Respond(ctx, http.StatusOK, "unsubscription.html", middleware.Result{
+		Status:  "unsubscribed",
+		Message: "Subscription cancelled",
+	})
 }
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	apiv2 "github.com/Rabiann/weather-mailer/internal/api/v2"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var ErrMissingToken = apperrors.New(apperrors.Unauthorized, "subscriber token is required")
+
+type (
+	// SubscriptionResourceController serves `/api/v2` subscription resources.
+	SubscriptionResourceController struct {
+		subscriptionService SubscriptionResourceService
+		subscriptionData    SubscriptionDataService
+		botGuard            BotGuard
+		logger              *slog.Logger
+	}
+
+	SubscriptionResourceService interface {
+		Subscribe(models.Subscription, context.Context) (bool, error)
+		Authorize(uint, uuid.UUID, context.Context) error
+		ConfirmSubscription(uint, uuid.UUID, context.Context) error
+		ChangeSubscription(uint, string, string, context.Context) (models.Subscription, error)
+	}
+
+	SubscriptionDataService interface {
+		GetSubscriptionById(uint, context.Context) (models.Subscription, error)
+		GetSubscriptionByEmail(string, context.Context) (*models.Subscription, error)
+		DeleteSubscription(uint, context.Context) error
+	}
+)
+
+var _ apiv2.ServerInterface = (*SubscriptionResourceController)(nil)
+
+func NewSubscriptionResourceController(subscriptionService SubscriptionResourceService, subscriptionData SubscriptionDataService, botGuard BotGuard, logger *slog.Logger) *SubscriptionResourceController {
+	return &SubscriptionResourceController{subscriptionService, subscriptionData, botGuard, logger}
+}
+
+func subscriptionResource(subscription models.Subscription) apiv2.Subscription {
+	return apiv2.Subscription{
+		Id:        int(subscription.ID),
+		Email:     subscription.Email,
+		City:      subscription.City,
+		Frequency: apiv2.Frequency(subscription.Frequency),
+		Confirmed: subscription.Confirmed,
+		CreatedAt: subscription.CreatedAt,
+	}
+}
+
+// bearerToken extracts subscriber token from `Authorization` header.
+func bearerToken(ctx *gin.Context) (uuid.UUID, error) {
+	scheme, raw, ok := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return uuid.Nil, ErrMissingToken
+	}
+
+	token, err := uuid.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// authorize reports whether request carries token of subscription id,
+// attaching error to context otherwise.
+func (s *SubscriptionResourceController) authorize(ctx *gin.Context, id uint) bool {
+	token, err := bearerToken(ctx)
+	if err == nil {
+		err = s.subscriptionService.Authorize(id, token, ctx)
+	}
+
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.Unauthorized {
+			ctx.Header("WWW-Authenticate", "Bearer")
+		}
+		_ = ctx.Error(err)
+		return false
+	}
+
+	return true
+}
+
+// CreateSubscription responds with created subscription, or with accepted
+// one when it existed unconfirmed and only its letter was sent again.
+func (s *SubscriptionResourceController) CreateSubscription(ctx *gin.Context) {
+	var subscription models.Subscription
+	if err := ctx.ShouldBindJSON(&subscription); err != nil {
+		_ = ctx.Error(bindingError(err))
+		return
+	}
+
+	if err := checkBot(ctx, s.botGuard, s.logger); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	created, err := s.subscriptionService.Subscribe(subscription, ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	stored, err := s.subscriptionData.GetSubscriptionByEmail(subscription.Email, ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	if !created {
+		ctx.JSON(http.StatusAccepted, subscriptionResource(*stored))
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/api/v2/subscriptions/%d", stored.ID))
+	ctx.JSON(http.StatusCreated, subscriptionResource(*stored))
+}
+
+func (s *SubscriptionResourceController) GetSubscription(ctx *gin.Context, id apiv2.SubscriptionId) {
+	if !s.authorize(ctx, uint(id)) {
+		return
+	}
+
+	subscription, err := s.subscriptionData.GetSubscriptionById(uint(id), ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, subscriptionResource(subscription))
+}
+
+func (s *SubscriptionResourceController) UpdateSubscription(ctx *gin.Context, id apiv2.SubscriptionId) {
+	if !s.authorize(ctx, uint(id)) {
+		return
+	}
+
+	var patch apiv2.SubscriptionPatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		_ = ctx.Error(bindingError(err))
+		return
+	}
+
+	var city, frequency string
+	if patch.City != nil {
+		city = strings.TrimSpace(*patch.City)
+	}
+	if patch.Frequency != nil {
+		frequency = string(*patch.Frequency)
+	}
+
+	subscription, err := s.subscriptionService.ChangeSubscription(uint(id), city, frequency, ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, subscriptionResource(subscription))
+}
+
+func (s *SubscriptionResourceController) DeleteSubscription(ctx *gin.Context, id apiv2.SubscriptionId) {
+	if !s.authorize(ctx, uint(id)) {
+		return
+	}
+
+	if err := s.subscriptionData.DeleteSubscription(uint(id), ctx); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *SubscriptionResourceController) CreateConfirmation(ctx *gin.Context, id apiv2.SubscriptionId) {
+	var confirmation apiv2.Confirmation
+	if err := ctx.ShouldBindJSON(&confirmation); err != nil {
+		_ = ctx.Error(bindingError(err))
+		return
+	}
+
+	token, err := uuid.Parse(confirmation.Token)
+	if err != nil {
+		_ = ctx.Error(ErrInvalidToken)
+		return
+	}
+
+	if err := s.subscriptionService.ConfirmSubscription(uint(id), token, ctx); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	subscription, err := s.subscriptionData.GetSubscriptionById(uint(id), ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, subscriptionResource(subscription))
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators adds custom validation tags to gin binding engine and
+// makes it report fields by their JSON names.
+func RegisterValidators() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+
+	if err := engine.RegisterValidation("rfc5322email", validateEmail); err != nil {
+		return err
+	}
+
+	return engine.RegisterValidation("frequency", validateFrequency)
+}
+
+// validateEmail accepts bare RFC 5322 addresses, without display name.
+func validateEmail(field validator.FieldLevel) bool {
+	value := field.Field().String()
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+func validateFrequency(field validator.FieldLevel) bool {
+	return slices.Contains(models.Frequencies, field.Field().String())
+}
+
+// bindingError converts binding failure into validation error with
+// explanation for every rejected field.
+func bindingError(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return apperrors.Wrap(apperrors.Validation, "malformed request body", err)
+	}
+
+	fields := make([]apperrors.FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, apperrors.FieldError{
+			Field:   fieldErr.Field(),
+			Message: validationMessage(fieldErr),
+		})
+	}
+
+	return apperrors.Invalid("invalid subscription request", fields...)
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+	case "rfc5322email":
+		return "must be a valid email address"
+	case "frequency":
+		return "must be one of: " + strings.Join(models.Frequencies, ", ")
+	default:
+		return "is invalid"
+	}
+}
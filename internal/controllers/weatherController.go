@@ -2,9 +2,12 @@ package controllers
 
 import (
 	"context"
+	"net/http"
+
+	"github.com/Rabiann/weather-mailer/internal/api"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
 	"github.com/Rabiann/weather-mailer/internal/models"
 	"github.com/gin-gonic/gin"
-	"net/http"
 )
 
 type (
@@ -21,16 +24,17 @@ func NewWeatherController(weatherService WeatherService) WeatherController {
 	return WeatherController{weatherService: weatherService}
 }
 
-func (w WeatherController) GetWeather(ctx *gin.Context) {
-	city, ok := ctx.GetQuery("city")
-	if !ok {
-		ctx.JSON(401, nil)
+func (w WeatherController) GetWeather(ctx *gin.Context, params api.GetWeatherParams) {
+	city := params.City
+	if city == "" {
+		_ = ctx.Error(apperrors.New(apperrors.Validation, "query parameter `city` is required"))
 		return
 	}
 
 	weather, err := w.weatherService.GetWeather(city, ctx)
 	if err != nil {
-		ctx.JSON(400, nil)
+		_ = ctx.Error(err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, weather)
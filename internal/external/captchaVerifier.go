@@ -0,0 +1,97 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/antispam"
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+)
+
+type (
+	// CaptchaProvider describes CAPTCHA service with reCAPTCHA compatible
+	// `siteverify` endpoint.
+	CaptchaProvider struct {
+		VerifyUrl     string
+		ScriptUrl     string
+		WidgetClass   string
+		ResponseField string
+	}
+
+	CaptchaVerifier struct {
+		provider CaptchaProvider
+		siteKey  string
+		secret   string
+		client   *http.Client
+	}
+
+	siteVerifyResponse struct {
+		Success bool `json:"success"`
+	}
+)
+
+var CaptchaProviders = map[string]CaptchaProvider{
+	"turnstile": {
+		VerifyUrl:     "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		ScriptUrl:     "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		WidgetClass:   "cf-turnstile",
+		ResponseField: "cf-turnstile-response",
+	},
+	"hcaptcha": {
+		VerifyUrl:     "https://api.hcaptcha.com/siteverify",
+		ScriptUrl:     "https://js.hcaptcha.com/1/api.js",
+		WidgetClass:   "h-captcha",
+		ResponseField: "h-captcha-response",
+	},
+	"recaptcha": {
+		VerifyUrl:     "https://www.google.com/recaptcha/api/siteverify",
+		ScriptUrl:     "https://www.google.com/recaptcha/api.js",
+		WidgetClass:   "g-recaptcha",
+		ResponseField: "g-recaptcha-response",
+	},
+}
+
+var _ antispam.CaptchaVerifier = (*CaptchaVerifier)(nil)
+
+func NewCaptchaVerifier(provider CaptchaProvider, siteKey string, secret string) *CaptchaVerifier {
+	return &CaptchaVerifier{provider, siteKey, secret, &http.Client{}}
+}
+
+func (c *CaptchaVerifier) Verify(response string, remoteIP string, ctx context.Context) error {
+	form := url.Values{"secret": {c.secret}, "response": {response}, "remoteip": {remoteIP}}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.provider.VerifyUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Unavailable, "CAPTCHA provider is unavailable", err)
+	}
+	defer resp.Body.Close()
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return apperrors.Wrap(apperrors.Unavailable, "CAPTCHA provider returned malformed response", err)
+	}
+
+	if !result.Success {
+		return antispam.ErrCaptcha
+	}
+
+	return nil
+}
+
+func (c *CaptchaVerifier) Widget() antispam.Widget {
+	return antispam.Widget{
+		ScriptUrl: c.provider.ScriptUrl,
+		Class:     c.provider.WidgetClass,
+		SiteKey:   c.siteKey,
+		Field:     c.provider.ResponseField,
+	}
+}
@@ -5,52 +5,151 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
 	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/metrics"
 	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// weatherProviderName labels metrics of weatherapi.com requests.
+const weatherProviderName = "weatherapi"
+
 type WeatherProvider struct {
 	config *config.Configuration
 	client *http.Client
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	usage models.WeatherUsage
+}
+
+func NewWeatherProvider(config *config.Configuration, logger *slog.Logger) *WeatherProvider {
+	now := time.Now().UTC()
+	return &WeatherProvider{
+		config: config,
+		client: &http.Client{Timeout: config.WeatherTimeout},
+		logger: logger,
+		usage:  models.WeatherUsage{Month: monthOf(now), Since: now, Quota: config.WeatherApiQuota},
+	}
+}
+
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
 }
 
-func NewWeatherProvider(config *config.Configuration) *WeatherProvider {
-	return &WeatherProvider{config, &http.Client{}}
+// countCall counts request against monthly quota of provider, which is
+// reset on first request of a new month.
+func (w *WeatherProvider) countCall() {
+	now := time.Now().UTC()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if month := monthOf(now); month.After(w.usage.Month) {
+		w.usage.Month = month
+		w.usage.Since = month
+		w.usage.Calls = 0
+	}
+	w.usage.Calls++
+}
+
+// Usage reports requests made to provider by this process in current month.
+func (w *WeatherProvider) Usage() models.WeatherUsage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.usage
 }
 
 func (w *WeatherProvider) GetWeather(city string, ctx context.Context) (models.Weather, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "WeatherProvider.GetWeather",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("weather.provider", weatherProviderName), attribute.String("weather.city", city)),
+	)
+
+	start := time.Now()
+	weather, status, err := w.getWeather(city, ctx)
+	if status != 0 {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	}
+	tracing.End(span, err)
+	metrics.WeatherRequestDuration.WithLabelValues(weatherProviderName).Observe(time.Since(start).Seconds())
+
+	outcome := metrics.Outcome(err)
+	// unknown city is a mistake of user, not failure of provider
+	if apperrors.KindOf(err) == apperrors.NotFound {
+		outcome = metrics.OutcomeNotFound
+	}
+	metrics.WeatherRequests.WithLabelValues(weatherProviderName, outcome).Inc()
+
+	if err != nil {
+		level := slog.LevelWarn
+		if outcome == metrics.OutcomeNotFound {
+			level = slog.LevelInfo
+		}
+
+		w.logger.Log(ctx, level, "weather request failed",
+			slog.String("city", city),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Any("error", err),
+		)
+	}
+
+	return weather, err
+}
+
+// getWeather requests weather of city, it also returns response status,
+// zero when there was no response.
+func (w *WeatherProvider) getWeather(city string, ctx context.Context) (models.Weather, int, error) {
 	var weather models.Weather
 	var weatherResponse models.WeatherResponse
-	url := fmt.Sprintf(w.config.WeatherApiAddress, w.config.WeatherApiKey, city)
+	// both are placed into query of address, so they cannot add parameters
+	// of their own
+	address := fmt.Sprintf(w.config.WeatherApiAddress, url.QueryEscape(w.config.WeatherApiKey), url.QueryEscape(city))
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
 	if err != nil {
-		return weather, err
+		return weather, 0, err
 	}
 
+	w.countCall()
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return weather, err
+		return weather, 0, apperrors.Wrap(apperrors.Unavailable, "weather provider is unavailable", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusBadRequest {
-		return weather, fmt.Errorf("city `%s` not exists", city)
+		return weather, resp.StatusCode, apperrors.New(apperrors.NotFound, fmt.Sprintf("city `%s` not exists", city))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return weather, resp.StatusCode, apperrors.New(apperrors.Unavailable, fmt.Sprintf("weather provider responded with %d", resp.StatusCode))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return weather, err
+		return weather, resp.StatusCode, apperrors.Wrap(apperrors.Unavailable, "weather provider is unavailable", err)
 	}
 
 	if err := json.Unmarshal(body, &weatherResponse); err != nil {
-		return weather, err
+		return weather, resp.StatusCode, apperrors.Wrap(apperrors.Unavailable, "weather provider returned malformed response", err)
 	}
 
 	weather.Description = weatherResponse.Text
 	weather.Humidity = weatherResponse.Humidity
 	weather.Temperature = weatherResponse.Temperature
 
-	return weather, nil
+	return weather, resp.StatusCode, nil
 }
@@ -0,0 +1,66 @@
+package external
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/config"
+)
+
+func TestGetWeatherEscapesCity(t *testing.T) {
+	var queries []map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"current":{"temp_c":21.5,"humidity":40,"condition":{"text":"Sunny"}}}`)
+	}))
+	defer server.Close()
+
+	configuration := &config.Configuration{
+		WeatherApiAddress: server.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
+		WeatherApiKey:     "key",
+		WeatherTimeout:    time.Second,
+	}
+	provider := NewWeatherProvider(configuration, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name string
+		city string
+	}{
+		{"multi-word city", "New York"},
+		{"ampersand", "Kyiv&key=other&aqi=yes"},
+		{"non-ASCII city", "Київ"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries = nil
+			weather, err := provider.GetWeather(test.city, context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if weather.Description != "Sunny" {
+				t.Errorf("weather is %+v", weather)
+			}
+
+			if len(queries) != 1 {
+				t.Fatalf("%d requests made, want 1", len(queries))
+			}
+			query := queries[0]
+			if q := query["q"]; len(q) != 1 || q[0] != test.city {
+				t.Errorf("city is sent as %q, want %q", q, test.city)
+			}
+			if key := query["key"]; len(key) != 1 || key[0] != "key" {
+				t.Errorf("key is sent as %q", key)
+			}
+			if aqi := query["aqi"]; len(aqi) != 1 || aqi[0] != "no" {
+				t.Errorf("aqi is sent as %q", aqi)
+			}
+		})
+	}
+}
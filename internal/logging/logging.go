@@ -0,0 +1,83 @@
+// Package logging builds structured logger whose records carry
+// correlation attributes attached to context, such as request or run ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type (
+	contextKey struct{}
+
+	// contextHandler adds attributes of record context, and trace ID when
+	// context carries span, to every record.
+	contextHandler struct {
+		slog.Handler
+	}
+)
+
+// New creates logger writing records of level and above to w in format,
+// `text` or `json`.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format `%s`", format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// With returns context whose log records carry attrs in addition to ones
+// parent context carries.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent := attrsOf(ctx)
+	combined := make([]slog.Attr, 0, len(parent)+len(attrs))
+	combined = append(combined, parent...)
+	combined = append(combined, attrs...)
+
+	return context.WithValue(ctx, contextKey{}, combined)
+}
+
+func attrsOf(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(attrsOf(ctx)...)
+	if ctx != nil {
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
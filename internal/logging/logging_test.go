@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// records decodes JSON records written into buffer.
+func records(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	decoder := json.NewDecoder(buffer)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRecordsCarryContextAttributes(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := New(&buffer, FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traceId := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceId, SpanID: trace.SpanID{1}, TraceFlags: trace.FlagsSampled})
+
+	request := With(context.Background(), slog.String("request_id", "request-1"))
+	run := With(request, slog.String("run_id", "run-1"))
+	traced := trace.ContextWithSpanContext(run, span)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]string
+	}{
+		{"no context attributes", context.Background(), map[string]string{}},
+		{"request", request, map[string]string{"request_id": "request-1"}},
+		{"run within request", run, map[string]string{"request_id": "request-1", "run_id": "run-1"}},
+		{"traced run", traced, map[string]string{"request_id": "request-1", "run_id": "run-1", "trace_id": traceId.String()}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// attributes of logger and its groups are kept too
+			logger.With(slog.String("component", "test")).InfoContext(test.ctx, "message", slog.Int("count", 1))
+
+			written := records(t, &buffer)
+			if len(written) != 1 {
+				t.Fatalf("%d records written", len(written))
+			}
+			record := written[0]
+			if record["component"] != "test" || record["count"] != 1.0 {
+				t.Errorf("attributes of record are lost: %v", record)
+			}
+
+			for _, key := range []string{"request_id", "run_id", "trace_id"} {
+				want, ok := test.want[key]
+				got, present := record[key]
+				if ok != present || (ok && got != want) {
+					t.Errorf("`%s` is %v, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithDoesNotChangeParent(t *testing.T) {
+	parent := With(context.Background(), slog.String("request_id", "request-1"))
+	With(parent, slog.String("run_id", "run-1"))
+
+	if attrs := attrsOf(parent); len(attrs) != 1 {
+		t.Errorf("parent carries %v", attrs)
+	}
+}
+
+func TestNewRejectsUnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("unknown format is accepted")
+	}
+}
@@ -0,0 +1,155 @@
+// Package metrics keeps Prometheus collectors of the application and
+// serves them on `/metrics`.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "weather_mailer"
+
+const (
+	OutcomeSuccess  = "success"
+	OutcomeError    = "error"
+	OutcomeNotFound = "not_found"
+
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Registry holds collectors of the application together with Go runtime
+// and process ones.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of served HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	WeatherRequests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "weather_provider_requests_total",
+		Help:      "Requests made to weather provider by outcome.",
+	}, []string{"provider", "outcome"})
+
+	WeatherRequestDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "weather_provider_request_duration_seconds",
+		Help:      "Duration of requests made to weather provider.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	WeatherCacheLookups = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "weather_cache_lookups_total",
+		Help:      "Lookups of weather cached during notifier run, by result.",
+	}, []string{"result"})
+
+	Mails = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mails_total",
+		Help:      "Letters handed to mail transport by type and outcome.",
+	}, []string{"type", "transport", "outcome"})
+
+	NotifierRunDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "notifier_run_duration_seconds",
+		Help:      "Duration of notifier runs by period.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"period", "outcome"})
+
+	NotifierSubscribers = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifier_subscribers_processed_total",
+		Help:      "Subscribers processed by notifier by period and outcome.",
+	}, []string{"period", "outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Outcome tells whether err is a success or an error for outcome label.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// Handler serves collectors of Registry.
+func Handler(logger *slog.Logger) http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	})
+}
+
+type (
+	SubscriberCounter interface {
+		CountByFrequency(context.Context) ([]models.FrequencyCount, error)
+	}
+
+	// subscriberCollector reads confirmed subscribers from database on scrape.
+	subscriberCollector struct {
+		counter SubscriberCounter
+		logger  *slog.Logger
+		desc    *prometheus.Desc
+	}
+)
+
+// scrapeTimeout bounds database query made on scrape.
+const scrapeTimeout = 5 * time.Second
+
+// RegisterSubscribers exposes gauge of confirmed subscribers per frequency.
+func RegisterSubscribers(counter SubscriberCounter, logger *slog.Logger) error {
+	return Registry.Register(&subscriberCollector{
+		counter: counter,
+		logger:  logger,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "confirmed_subscribers"),
+			"Confirmed subscribers by frequency.",
+			[]string{"frequency"}, nil,
+		),
+	})
+}
+
+func (c *subscriberCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.desc
+}
+
+func (c *subscriberCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	counts, err := c.counter.CountByFrequency(ctx)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "subscribers not counted", slog.Any("error", err))
+		metrics <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	// frequencies without subscribers are reported as zero, not left out
+	confirmed := map[string]int64{models.FrequencyHourly: 0, models.FrequencyDaily: 0}
+	for _, count := range counts {
+		confirmed[count.Frequency] = count.Confirmed
+	}
+
+	for frequency, value := range confirmed {
+		metrics <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(value), frequency)
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// ActorKey is context key under which AdminAuth stores name of operator.
+const ActorKey = "actor"
+
+var ErrAdminUnauthorized = apperrors.New(apperrors.Unauthorized, "valid admin token is required")
+
+// AdminAuth lets through requests carrying one of operator bearer tokens,
+// tokens map operator name to its token.
+func AdminAuth(tokens map[string]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scheme, raw, ok := strings.Cut(ctx.GetHeader("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			presented := []byte(strings.TrimSpace(raw))
+			for name, token := range tokens {
+				if subtle.ConstantTimeCompare(presented, []byte(token)) == 1 {
+					ctx.Set(ActorKey, name)
+					ctx.Next()
+					return
+				}
+			}
+		}
+
+		ctx.Header("WWW-Authenticate", "Bearer")
+		WriteProblem(ctx, NewProblem(ErrAdminUnauthorized, ctx.Request.URL.Path))
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ApiKeyHeader = "X-API-Key"
+	// ApiKeyKey is context key under which APIKeys stores authenticated key.
+	ApiKeyKey = "apiKey"
+)
+
+var ErrScope = apperrors.New(apperrors.Forbidden, "API key has no scope required by this endpoint")
+
+type (
+	ApiKeyAuthenticator interface {
+		Authenticate(string, context.Context) (models.ApiKey, error)
+	}
+
+	// Route is method and full path of gin route.
+	Route struct {
+		Method string
+		Path   string
+	}
+)
+
+// APIKeys authenticates requests carrying `X-API-Key` and checks that key
+// has scope of the route, scopes map routes onto them. Requests without
+// key pass through anonymously.
+func APIKeys(authenticator ApiKeyAuthenticator, scopes map[Route]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		raw := ctx.GetHeader(ApiKeyHeader)
+		if raw == "" {
+			ctx.Next()
+			return
+		}
+
+		key, err := authenticator.Authenticate(raw, ctx)
+		if err == nil {
+			if scope, ok := scopes[Route{ctx.Request.Method, ctx.FullPath()}]; ok && !key.HasScope(scope) {
+				err = ErrScope
+			}
+		}
+
+		if err != nil {
+			switch apperrors.KindOf(err) {
+			case apperrors.Unauthorized:
+				ctx.Header("WWW-Authenticate", "ApiKey header=\""+ApiKeyHeader+"\"")
+			case apperrors.Throttled:
+				tomorrow := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+				ctx.Header("Retry-After", strconv.Itoa(int(time.Until(tomorrow).Seconds())+1))
+			}
+			WriteProblem(ctx, NewProblem(err, ctx.Request.URL.Path))
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set(ApiKeyKey, key)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// memoryApiKeys stores keys and their usage of the current day.
+	memoryApiKeys struct {
+		keys  []models.ApiKey
+		usage map[uint]int
+	}
+
+	noAudit struct{}
+
+	directUnitOfWork struct{}
+)
+
+func (m *memoryApiKeys) AddKey(key models.ApiKey, _ context.Context) (models.ApiKey, error) {
+	key.ID = uint(len(m.keys) + 1)
+	m.keys = append(m.keys, key)
+	return key, nil
+}
+
+func (m *memoryApiKeys) GetKeys(context.Context) ([]models.ApiKey, error) {
+	return m.keys, nil
+}
+
+func (m *memoryApiKeys) GetKeyByHash(hash string, _ context.Context) (models.ApiKey, error) {
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.ApiKey{}, apperrors.New(apperrors.NotFound, "API key not found")
+}
+
+func (m *memoryApiKeys) RevokeKey(id uint, _ context.Context) error {
+	now := time.Now()
+	m.keys[id-1].RevokedAt = &now
+	return nil
+}
+
+func (m *memoryApiKeys) CountUsage(id uint, _ time.Time, _ context.Context) (int, error) {
+	m.usage[id]++
+	return m.usage[id], nil
+}
+
+func (noAudit) AddRecord(models.AuditRecord, context.Context) error { return nil }
+func (noAudit) GetRecords(int, int, context.Context) ([]models.AuditRecord, int64, error) {
+	return nil, 0, nil
+}
+
+func (directUnitOfWork) Execute(fn func(context.Context) error, ctx context.Context) error {
+	return fn(ctx)
+}
+
+// newApiKeyRouter serves weather, subscribing and confirmation behind
+// APIKeys, responding with name of key request was authenticated with.
+func newApiKeyRouter(authenticator ApiKeyAuthenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	router.Use(APIKeys(authenticator, map[Route]string{
+		{Method: http.MethodGet, Path: "/api/weather"}:    models.ScopeWeatherRead,
+		{Method: http.MethodPost, Path: "/api/subscribe"}: models.ScopeSubscriptionsWrite,
+	}))
+
+	respond := func(ctx *gin.Context) {
+		name := "anonymous"
+		if key, ok := ctx.Get(ApiKeyKey); ok {
+			name = key.(models.ApiKey).Name
+		}
+		ctx.String(http.StatusOK, name)
+	}
+	router.GET("/api/weather", respond)
+	router.POST("/api/subscribe", respond)
+	router.GET("/api/confirm/:token", respond)
+
+	return router
+}
+
+func serveWithKey(router http.Handler, method string, path string, key string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	if key != "" {
+		request.Header.Set(ApiKeyHeader, key)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAPIKeysEnforceScopes(t *testing.T) {
+	service := services.NewApiKeyService(&memoryApiKeys{usage: map[uint]int{}}, noAudit{}, directUnitOfWork{})
+	ctx := context.Background()
+
+	_, weatherKey, err := service.CreateKey("test", "weather", []string{models.ScopeWeatherRead}, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, partnerKey, err := service.CreateKey("test", "partner", models.Scopes, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedKey, err := service.CreateKey("test", "revoked", models.Scopes, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RevokeKey("test", revoked.ID, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	router := newApiKeyRouter(service)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		status int
+		body   string
+	}{
+		{"anonymous request passes", http.MethodGet, "/api/weather", "", http.StatusOK, "anonymous"},
+		{"key with scope passes", http.MethodGet, "/api/weather", weatherKey, http.StatusOK, "weather"},
+		{"key without scope is forbidden", http.MethodPost, "/api/subscribe", weatherKey, http.StatusForbidden, ""},
+		{"key with every scope passes", http.MethodPost, "/api/subscribe", partnerKey, http.StatusOK, "partner"},
+		{"route without scope takes any key", http.MethodGet, "/api/confirm/token", weatherKey, http.StatusOK, "weather"},
+		{"unknown key is unauthorized", http.MethodGet, "/api/weather", services.ApiKeyPrefix + "unknown", http.StatusUnauthorized, ""},
+		{"revoked key is unauthorized", http.MethodGet, "/api/weather", revokedKey, http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveWithKey(router, test.method, test.path, test.key)
+			if recorder.Code != test.status {
+				t.Fatalf("responded with %d, want %d: %s", recorder.Code, test.status, recorder.Body)
+			}
+
+			switch test.status {
+			case http.StatusOK:
+				if recorder.Body.String() != test.body {
+					t.Errorf("authenticated as %q, want %q", recorder.Body, test.body)
+				}
+			case http.StatusUnauthorized:
+				if recorder.Header().Get("WWW-Authenticate") == "" {
+					t.Error("unauthorized response has no WWW-Authenticate")
+				}
+				fallthrough
+			default:
+				if recorder.Header().Get("Content-Type") != ProblemContentType {
+					t.Errorf("rejection is %s, want problem", recorder.Header().Get("Content-Type"))
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeysEnforceDailyQuota(t *testing.T) {
+	repository := &memoryApiKeys{usage: map[uint]int{}}
+	service := services.NewApiKeyService(repository, noAudit{}, directUnitOfWork{})
+	ctx := context.Background()
+
+	limited, limitedKey, err := service.CreateKey("test", "limited", []string{models.ScopeWeatherRead}, 2, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, unlimitedKey, err := service.CreateKey("test", "unlimited", []string{models.ScopeWeatherRead}, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newApiKeyRouter(service)
+	for i := 1; i <= 2; i++ {
+		if recorder := serveWithKey(router, http.MethodGet, "/api/weather", limitedKey); recorder.Code != http.StatusOK {
+			t.Fatalf("request %d responded with %d", i, recorder.Code)
+		}
+	}
+
+	recorder := serveWithKey(router, http.MethodGet, "/api/weather", limitedKey)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over quota responded with %d", recorder.Code)
+	}
+	// quota starts over at midnight UTC
+	retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > 24*60*60+1 {
+		t.Errorf("Retry-After is %q", recorder.Header().Get("Retry-After"))
+	}
+
+	// a new day
+	repository.usage[limited.ID] = 0
+	if recorder := serveWithKey(router, http.MethodGet, "/api/weather", limitedKey); recorder.Code != http.StatusOK {
+		t.Errorf("request of next day responded with %d", recorder.Code)
+	}
+
+	// keys without quota are not limited
+	for i := 1; i <= 10; i++ {
+		if recorder := serveWithKey(router, http.MethodGet, "/api/weather", unlimitedKey); recorder.Code != http.StatusOK {
+			t.Fatalf("request %d with unlimited key responded with %d", i, recorder.Code)
+		}
+	}
+}
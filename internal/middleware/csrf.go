@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CSRFCookie = "csrf"
+	// CSRFField is name of hidden form input carrying token.
+	CSRFField = "csrf_token"
+	// CSRFHeader carries token for scripts posting forms.
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfTokenKey = "csrfToken"
+)
+
+var ErrCSRF = apperrors.New(apperrors.Forbidden, "form has expired or was not sent from this site, please reload the page")
+
+// csrfContentTypes are ones browsers post cross-site without preflight,
+// the rest can only come from same origin or non-browser clients.
+var csrfContentTypes = map[string]bool{
+	gin.MIMEPOSTForm:          true,
+	gin.MIMEMultipartPOSTForm: true,
+	gin.MIMEPlain:             true,
+}
+
+// CSRF binds every client to random cookie and issues form token signed
+// with secret over it, available to handlers with CSRFToken. Unsafe
+// requests with content types of HTML forms are rejected unless they carry
+// token of their cookie, JSON, bearer token and API key clients are not affected. Rejections are
+// rendered with errorPage for browsers, so it should be registered after
+// ErrorHandler.
+func CSRF(secret []byte, errorPage string) gin.HandlerFunc {
+	sign := func(cookie string) string {
+		h := hmac.New(sha256.New, secret)
+		h.Write([]byte("csrf:" + cookie))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	return func(ctx *gin.Context) {
+		cookie, err := ctx.Cookie(CSRFCookie)
+		if err != nil || cookie == "" {
+			cookie, err = randomCookie()
+			if err != nil {
+				_ = ctx.Error(err)
+				ctx.Abort()
+				return
+			}
+			secure := ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https"
+			ctx.SetSameSite(http.SameSiteLaxMode)
+			ctx.SetCookie(CSRFCookie, cookie, 0, "/", "", secure, true)
+		}
+		ctx.Set(csrfTokenKey, sign(cookie))
+
+		if isSafeMethod(ctx.Request.Method) || !csrfContentTypes[ctx.ContentType()] || hasCredentialsHeader(ctx) {
+			ctx.Next()
+			return
+		}
+
+		token := ctx.GetHeader(CSRFHeader)
+		if token == "" {
+			token = ctx.PostForm(CSRFField)
+		}
+
+		if !hmac.Equal([]byte(token), []byte(sign(cookie))) {
+			SetErrorPage(ctx, errorPage)
+			_ = ctx.Error(ErrCSRF)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// CSRFToken returns token which forms rendered for this request should
+// carry in CSRFField.
+func CSRFToken(ctx *gin.Context) string {
+	return ctx.GetString(csrfTokenKey)
+}
+
+func randomCookie() (string, error) {
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
+
+// hasCredentialsHeader reports whether client authenticates with bearer
+// token or API key, which browsers never attach to cross-site requests on
+// their own.
+func hasCredentialsHeader(ctx *gin.Context) bool {
+	scheme, _, _ := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	return strings.EqualFold(scheme, "Bearer") || ctx.GetHeader(ApiKeyHeader) != ""
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
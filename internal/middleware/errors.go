@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+func NewProblem(err error, instance string) Problem {
+	kind := apperrors.KindOf(err)
+	status := kind.HTTPStatus()
+
+	return Problem{
+		Type:     "/problems/" + kind.String(),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   apperrors.MessageOf(err),
+		Instance: instance,
+		Errors:   apperrors.FieldsOf(err),
+	}
+}
+
+func WriteProblem(ctx *gin.Context, problem Problem) {
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.JSON(problem.Status, problem)
+}
+
+// ErrorHandler turns the last error attached to context by handler into
+// problem response, unless handler already wrote one. Browsers get error
+// page set by handler instead, when there is one. Errors which are not
+// caused by client are logged, as their details are not shown to it.
+func ErrorHandler(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+
+		err := ctx.Errors.Last().Err
+		problem := NewProblem(err, ctx.Request.URL.Path)
+		if problem.Status >= http.StatusInternalServerError {
+			logger.ErrorContext(ctx.Request.Context(), "request failed", slog.String("route", ctx.FullPath()), slog.Any("error", err))
+		}
+
+		if page := ctx.GetString(ErrorPageKey); page != "" && WantsHTML(ctx) {
+			ctx.HTML(problem.Status, page, problem)
+			return
+		}
+
+		WriteProblem(ctx, problem)
+	}
+}
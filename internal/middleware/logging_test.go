@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Rabiann/weather-mailer/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRequestIDIsLogged(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := logging.New(&buffer, logging.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(RequestID(), AccessLog(logger))
+	router.GET("/api/weather", func(ctx *gin.Context) {
+		logger.InfoContext(ctx, "handled")
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name string
+		id   string
+		kept bool
+	}{
+		{"valid ID is kept", "client-request-1", true},
+		{"longest ID is kept", strings.Repeat("a", maxRequestIDLength), true},
+		{"missing ID is generated", "", false},
+		{"oversized ID is replaced", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"ID with space is replaced", "client request", false},
+		{"ID with control character is replaced", "client\x1brequest", false},
+		{"non-ASCII ID is replaced", "запит", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+			if test.id != "" {
+				request.Header.Set(RequestIDHeader, test.id)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			id := recorder.Header().Get(RequestIDHeader)
+			if test.kept && id != test.id {
+				t.Errorf("request ID is %q, want %q", id, test.id)
+			}
+			if !test.kept {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("request ID %q is not generated", id)
+				}
+			}
+
+			// record of handler and access log both carry it
+			var messages []string
+			decoder := json.NewDecoder(&buffer)
+			for decoder.More() {
+				var record map[string]any
+				if err := decoder.Decode(&record); err != nil {
+					t.Fatal(err)
+				}
+				if record["request_id"] != id {
+					t.Errorf("record %q carries request ID %v, want %q", record["msg"], record["request_id"], id)
+				}
+				messages = append(messages, record["msg"].(string))
+			}
+			if len(messages) != 2 {
+				t.Errorf("records written: %v", messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// ErrorPageKey is context key under which handler names HTML template
+// to render its errors with for browsers.
+const ErrorPageKey = "errorPage"
+
+// Result is JSON body of successful action, counterpart of Problem.
+type Result struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// WantsHTML reports whether client prefers HTML page over JSON. Clients
+// which do not state preference get JSON.
+func WantsHTML(ctx *gin.Context) bool {
+	return ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// Respond renders page for browsers and result for API clients.
+func Respond(ctx *gin.Context, status int, page string, result Result) {
+	if WantsHTML(ctx) {
+		ctx.HTML(status, page, result)
+		return
+	}
+
+	ctx.JSON(status, result)
+}
+
+// SetErrorPage makes ErrorHandler render page instead of problem JSON for
+// clients which prefer HTML.
+func SetErrorPage(ctx *gin.Context, page string) {
+	ctx.Set(ErrorPageKey, page)
+}
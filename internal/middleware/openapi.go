@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(ProblemContentType, openapi3filter.RegisteredBodyDecoder("application/json"))
+}
+
+// ResponseErrorHandler receives error of response which does not match
+// API specification.
+type ResponseErrorHandler func(ctx *gin.Context, err error)
+
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w bufferedWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w bufferedWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// OpenAPIValidator validates requests under baseUrl against spec and rejects
+// ones which do not conform to it. When responseErrors is not nil, responses
+// are validated too and mismatches are passed to it, as they are bugs of
+// server rather than of client. It should be registered before
+// ErrorHandler, so problem responses are validated as well.
+func OpenAPIValidator(spec *openapi3.T, baseUrl string, responseErrors ResponseErrorHandler) (gin.HandlerFunc, error) {
+	spec.Servers = nil
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(ctx *gin.Context) {
+		if !strings.HasPrefix(ctx.Request.URL.Path, baseUrl+"/") {
+			ctx.Next()
+			return
+		}
+
+		request := ctx.Request.Clone(ctx)
+		request.URL.Path = strings.TrimPrefix(request.URL.Path, baseUrl)
+		request.URL.RawPath = ""
+
+		route, pathParams, err := router.FindRoute(request)
+		if err != nil {
+			ctx.Next()
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+
+		err = openapi3filter.ValidateRequest(ctx, input)
+		// validator consumes body and puts its copy into cloned request
+		ctx.Request.Body = request.Body
+		if err != nil {
+			err = specificationError(err)
+			WriteProblem(ctx, NewProblem(err, ctx.Request.URL.Path))
+			ctx.Abort()
+			return
+		}
+
+		if responseErrors == nil {
+			ctx.Next()
+			return
+		}
+
+		writer := bufferedWriter{ResponseWriter: ctx.Writer, body: &bytes.Buffer{}}
+		ctx.Writer = writer
+		ctx.Next()
+
+		if err := validateResponse(ctx, input, writer); err != nil {
+			responseErrors(ctx, err)
+		}
+	}, nil
+}
+
+// specificationError converts validator error into domain one, pointing at
+// rejected parameter or body field when it is known.
+func specificationError(err error) error {
+	appErr := apperrors.Wrap(apperrors.Validation, "request does not match API specification", err)
+
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return appErr
+	}
+
+	field := apperrors.FieldError{Field: "body", Message: requestErr.Reason}
+	if requestErr.Parameter != nil {
+		field.Field = requestErr.Parameter.Name
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			field.Field = strings.Join(pointer, ".")
+		}
+		field.Message = schemaErr.Reason
+	}
+
+	if field.Message == "" && requestErr.Err != nil {
+		field.Message = requestErr.Err.Error()
+	}
+
+	appErr.Fields = []apperrors.FieldError{field}
+	return appErr
+}
+
+// LogResponseError logs response which does not match API specification.
+func LogResponseError(ctx *gin.Context, err error) {
+	slog.WarnContext(ctx, "response does not match API specification", slog.String("method", ctx.Request.Method), slog.String("route", ctx.FullPath()), slog.Any("error", err))
+}
+
+func validateResponse(ctx context.Context, input *openapi3filter.RequestValidationInput, writer bufferedWriter) error {
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 writer.Status(),
+		Header:                 writer.Header(),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	responseInput.SetBodyBytes(writer.body.Bytes())
+
+	return openapi3filter.ValidateResponse(ctx, responseInput)
+}
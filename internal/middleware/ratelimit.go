@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPeekedBody is how much of request body TargetEmail reads looking for email.
+const maxPeekedBody = 64 << 10
+
+var ErrRateLimited = apperrors.New(apperrors.Throttled, "too many requests, try again later")
+
+type (
+	// RateLimitRule limits requests to route, grouped by key. Requests for
+	// which Key returns empty string are not counted. Nil Key counts all
+	// requests to route together.
+	RateLimitRule struct {
+		Name   string
+		Method string
+		Path   string
+		Limit  ratelimit.Limit
+		Key    KeyFunc
+	}
+
+	KeyFunc func(ctx *gin.Context) string
+)
+
+// ClientIP groups requests by address of client.
+func ClientIP(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+// TargetEmail groups requests by `email` field of JSON or form body, so one
+// address can not be flooded with letters from many clients.
+func TargetEmail(ctx *gin.Context) string {
+	// CSRF parses forms before rate limits run, which drains body
+	if ctx.Request.PostForm != nil {
+		return strings.ToLower(strings.TrimSpace(ctx.Request.PostForm.Get("email")))
+	}
+
+	if ctx.Request.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxPeekedBody))
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+	if err != nil {
+		return ""
+	}
+
+	var email string
+	switch ctx.ContentType() {
+	case gin.MIMEJSON:
+		var fields struct {
+			Email string `json:"email"`
+		}
+		if json.Unmarshal(body, &fields) == nil {
+			email = fields.Email
+		}
+	case gin.MIMEPOSTForm:
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			email = values.Get("email")
+		}
+	}
+
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RateLimit rejects requests exceeding limits of matching rules with 429
+// and `Retry-After`. Requests are let through when store fails, as losing
+// the service is worse than losing limits for a while. Clients with API
+// key are held back by its quota instead, so it should be registered
+// after APIKeys.
+func RateLimit(store ratelimit.Store, rules ...RateLimitRule) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := ctx.Get(ApiKeyKey); ok {
+			ctx.Next()
+			return
+		}
+
+		for _, rule := range rules {
+			if !rule.Limit.Enabled() || rule.Method != ctx.Request.Method || rule.Path != ctx.FullPath() {
+				continue
+			}
+
+			key := rule.Name
+			if rule.Key != nil {
+				value := rule.Key(ctx)
+				if value == "" {
+					continue
+				}
+				// keys are hashed to keep addresses out of shared store
+				sum := sha256.Sum256([]byte(value))
+				key += ":" + hex.EncodeToString(sum[:])
+			}
+
+			count, reset, err := store.Hit(key, rule.Limit.Window, ctx)
+			if err != nil {
+				slog.ErrorContext(ctx.Request.Context(), "rate limit is not checked", slog.String("rule", rule.Name), slog.Any("error", err))
+				continue
+			}
+
+			if count > rule.Limit.Requests {
+				retryAfter := math.Ceil(time.Until(reset).Seconds())
+				ctx.Header("Retry-After", strconv.Itoa(max(int(retryAfter), 1)))
+				WriteProblem(ctx, NewProblem(ErrRateLimited, ctx.Request.URL.Path))
+				ctx.Abort()
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
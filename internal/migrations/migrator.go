@@ -0,0 +1,316 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// lockKey identifies advisory lock held while migrations are applied or
+// rolled back, so that instances started together do not run them twice.
+const lockKey = 4_613_200_529
+
+var ErrSchemaBehind = errors.New("database schema is behind, run `migrate up`")
+
+type (
+	Migration struct {
+		Version  uint
+		Name     string
+		Up       string
+		Down     string
+		Checksum string
+	}
+
+	// SchemaMigration is a row of `schema_migrations` table.
+	SchemaMigration struct {
+		Version   uint `gorm:"primaryKey;autoIncrement:false"`
+		Name      string
+		Checksum  string
+		AppliedAt time.Time
+	}
+
+	MigrationStatus struct {
+		Migration
+		Applied   bool
+		AppliedAt time.Time
+		Modified  bool
+	}
+
+	Migrator struct {
+		db         *gorm.DB
+		migrations []Migration
+	}
+)
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// load reads `<version>_<name>.up.sql` and `<version>_<name>.down.sql` pairs
+// and orders them by version.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("unexpected migration file `%s`", entry.Name())
+		}
+
+		rawVersion, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file `%s` has no name", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(rawVersion, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("migration file `%s` has invalid version", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = migration
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+			migration.Checksum = checksum(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d should have both up and down files", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func applied(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	if err := db.Exec(createSchemaMigrations).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if result := db.Order("version").Find(&rows); result.Error != nil {
+		return nil, result.Error
+	}
+
+	applied := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// locked runs fn holding advisory lock on a single connection, which fn
+// gets. Other migrators wait for the lock to be released.
+func (m *Migrator) locked(ctx context.Context, fn func(db *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		db := conn.Session(&gorm.Session{})
+		if err := db.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		// released even when ctx is cancelled, as connection goes back to pool
+		defer db.WithContext(context.WithoutCancel(ctx)).Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+		return fn(db)
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			status.Modified = row.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Verify fails when applied migration was modified after being applied,
+// when database knows migration this binary does not, or when there are
+// pending migrations.
+func (m *Migrator) Verify(ctx context.Context) error {
+	applied, err := applied(m.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	known := make(map[uint]bool, len(m.migrations))
+	pending := 0
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		row, ok := applied[migration.Version]
+		if !ok {
+			pending++
+			continue
+		}
+
+		if row.Checksum != migration.Checksum {
+			return fmt.Errorf("checksum of applied migration %d_%s differs from file", migration.Version, migration.Name)
+		}
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("database has unknown migration %d applied", version)
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migration(s)", ErrSchemaBehind, pending)
+	}
+
+	return nil
+}
+
+// Up applies all pending migrations in order, each one in own transaction.
+// Migrations applied by another instance meanwhile are skipped.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		// read under lock, as another instance may have just applied them
+		applied, err := applied(db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if row, ok := applied[migration.Version]; ok {
+				if row.Checksum != migration.Checksum {
+					return fmt.Errorf("checksum of applied migration %d_%s differs from file", migration.Version, migration.Name)
+				}
+				continue
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+				}
+
+				return tx.Create(&SchemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					Checksum:  migration.Checksum,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the latest applied migration. It returns nil when nothing is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var rolledBack *Migration
+
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		applied, err := applied(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+				}
+
+				return tx.Delete(&SchemaMigration{}, migration.Version).Error
+			})
+			if err != nil {
+				return err
+			}
+
+			rolledBack = &migration
+			return nil
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rolledBack, nil
+}
@@ -0,0 +1,139 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDatabaseEnv names disposable database tests may wipe.
+const testDatabaseEnv = "TEST_DATABASE_URL"
+
+func TestMigrationsHaveBothDirections(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != uint(i+1) {
+			t.Errorf("migration %d_%s should have version %d", migration.Version, migration.Name, i+1)
+		}
+		if strings.TrimSpace(migration.Up) == "" {
+			t.Errorf("migration %d_%s has empty up", migration.Version, migration.Name)
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			t.Errorf("migration %d_%s has empty down", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestLoadRejectsMissingDirection(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no down": {
+			"sql/0001_initial.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+		},
+		"no up": {
+			"sql/0001_initial.down.sql": {Data: []byte("DROP TABLE a;")},
+		},
+		"empty down": {
+			"sql/0001_initial.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+			"sql/0001_initial.down.sql": {Data: []byte{}},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := load(fsys); err == nil {
+				t.Fatal("loaded")
+			}
+		})
+	}
+}
+
+func testMigrator(t *testing.T) *Migrator {
+	t.Helper()
+
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("`%s` is not set", testDatabaseEnv)
+	}
+
+	db, err := gorm.Open(postgres.Open(url), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// start from empty schema
+	for {
+		migration, err := migrator.Down(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migration == nil {
+			return migrator
+		}
+	}
+}
+
+// Instances started together each run `migrate up`, every migration should
+// still be applied once.
+func TestConcurrentUpAppliesOnce(t *testing.T) {
+	migrator := testMigrator(t)
+
+	const instances = 4
+	var wg sync.WaitGroup
+	applied := make([][]Migration, instances)
+	errs := make([]error, instances)
+	for i := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied[i], errs[i] = migrator.Up(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range instances {
+		if errs[i] != nil {
+			t.Fatalf("instance %d: %v", i, errs[i])
+		}
+		total += len(applied[i])
+	}
+	if total != len(migrator.migrations) {
+		t.Fatalf("%d migrations applied, want %d", total, len(migrator.migrations))
+	}
+
+	if err := migrator.Verify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownReversesUp(t *testing.T) {
+	migrator := testMigrator(t)
+	ctx := context.Background()
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for range migrator.migrations {
+		if _, err := migrator.Down(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("applying again after rollback: %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+-- Raw tokens can not be restored from hashes, all issued tokens are dropped.
+DELETE FROM tokens;
+ALTER TABLE tokens DROP COLUMN hash;
+ALTER TABLE tokens ADD COLUMN id UUID PRIMARY KEY;
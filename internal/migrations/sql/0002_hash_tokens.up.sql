@@ -0,0 +1,15 @@
+-- Tokens are stored as hex-encoded SHA-256 of their textual UUID form,
+-- so links which were already emailed stay valid.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'tokens' AND column_name = 'id'
+    ) THEN
+        ALTER TABLE tokens ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+        UPDATE tokens SET hash = encode(sha256(id::text::bytea), 'hex');
+        ALTER TABLE tokens DROP COLUMN id;
+        ALTER TABLE tokens ALTER COLUMN hash SET NOT NULL;
+        ALTER TABLE tokens ADD PRIMARY KEY (hash);
+    END IF;
+END $$;
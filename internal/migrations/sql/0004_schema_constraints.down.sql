@@ -0,0 +1,8 @@
+ALTER TABLE tokens DROP CONSTRAINT IF EXISTS tokens_subscription_id_fkey;
+ALTER TABLE tokens
+    ADD CONSTRAINT tokens_subscription_id_fkey
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id);
+
+ALTER TABLE tokens ALTER COLUMN expires DROP NOT NULL;
+ALTER TABLE tokens ALTER COLUMN subscription_id DROP NOT NULL;
+ALTER TABLE subscriptions ALTER COLUMN email DROP NOT NULL;
@@ -0,0 +1,13 @@
+-- Brings databases created by GORM AutoMigrate in line with migrations 0001-0003.
+DELETE FROM tokens WHERE subscription_id IS NULL;
+DELETE FROM subscriptions WHERE email IS NULL;
+
+ALTER TABLE subscriptions ALTER COLUMN email SET NOT NULL;
+ALTER TABLE tokens ALTER COLUMN subscription_id SET NOT NULL;
+ALTER TABLE tokens ALTER COLUMN expires SET NOT NULL;
+
+ALTER TABLE tokens DROP CONSTRAINT IF EXISTS fk_subscriptions_tokens;
+ALTER TABLE tokens DROP CONSTRAINT IF EXISTS tokens_subscription_id_fkey;
+ALTER TABLE tokens
+    ADD CONSTRAINT tokens_subscription_id_fkey
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS audit_records;
+DROP TABLE IF EXISTS deliveries;
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS subscription_id;
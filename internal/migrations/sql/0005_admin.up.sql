@@ -0,0 +1,24 @@
+ALTER TABLE outbox_messages ADD COLUMN subscription_id INTEGER;
+
+CREATE TABLE deliveries (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX deliveries_subscription_id_idx ON deliveries (subscription_id, created_at);
+
+-- Audit records outlive subscriptions they mention, so there is no foreign key.
+CREATE TABLE audit_records (
+    id SERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    subscription_id INTEGER,
+    details TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
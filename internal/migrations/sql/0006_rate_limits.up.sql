@@ -0,0 +1,5 @@
+CREATE UNLOGGED TABLE rate_limits (
+    key VARCHAR(128) PRIMARY KEY,
+    window_end TIMESTAMP NOT NULL,
+    count INTEGER NOT NULL
+);
@@ -0,0 +1,7 @@
+-- Links with raw tokens are built when letter is sent, so they are not kept.
+-- Pending messages without subscription can not be sent anymore.
+UPDATE outbox_messages
+SET attempts = 5, last_error = 'enqueued with link, resubscribe to get new one'
+WHERE sent_at IS NULL AND subscription_id IS NULL;
+
+ALTER TABLE outbox_messages DROP COLUMN payload;
@@ -0,0 +1,3 @@
+-- Messages are claimed for a while instead of being locked, so that letters
+-- are sent outside of transactions.
+ALTER TABLE outbox_messages ADD COLUMN claimed_until TIMESTAMP;
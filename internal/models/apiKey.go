@@ -0,0 +1,33 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeWeatherRead        = "weather:read"
+	ScopeSubscriptionsWrite = "subscriptions:write"
+)
+
+var Scopes = []string{ScopeWeatherRead, ScopeSubscriptionsWrite}
+
+// ApiKey is credential of programmatic client. Only hash of the key is
+// stored, Prefix is kept to tell keys apart.
+type ApiKey struct {
+	ID         uint
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     string
+	DailyQuota int
+	UsageCount int64
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (k ApiKey) HasScope(scope string) bool {
+	return slices.Contains(strings.Split(k.Scopes, ","), scope)
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type AuditRecord struct {
+	ID             uint
+	Actor          string
+	Action         string
+	SubscriptionID uint
+	Details        string
+	CreatedAt      time.Time
+}
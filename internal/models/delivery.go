@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	DeliveryConfirmation = "confirmation"
+	DeliveryWeather      = "weather"
+
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+type Delivery struct {
+	ID             uint
+	SubscriptionID uint
+	Kind           string
+	Recipient      string
+	Status         string
+	Error          string
+	CreatedAt      time.Time
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	HealthOk       = "ok"
+	HealthDegraded = "degraded"
+	HealthFail     = "fail"
+)
+
+type (
+	// Health is readiness of the application with status of every
+	// component it depends on.
+	Health struct {
+		Status     string                     `json:"status"`
+		CheckedAt  time.Time                  `json:"checkedAt"`
+		Components map[string]ComponentHealth `json:"components"`
+	}
+
+	// ComponentHealth is result of one check. Failure of component which is
+	// not required degrades the application instead of failing it.
+	ComponentHealth struct {
+		Status   string         `json:"status"`
+		Required bool           `json:"required"`
+		Duration string         `json:"duration"`
+		Error    string         `json:"error,omitempty"`
+		Details  map[string]any `json:"details,omitempty"`
+	}
+)
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OutboxMessage is letter waiting to be sent. It holds no links, those are
+// built with fresh tokens when letter is dispatched.
+type OutboxMessage struct {
+	ID             uint
+	SubscriptionID uint
+	Kind           string `gorm:"not null"`
+	Recipient      string `gorm:"not null"`
+	Attempts       int
+	LastError      string
+	ClaimedUntil   *time.Time
+	SentAt         *time.Time
+	CreatedAt      time.Time
+}
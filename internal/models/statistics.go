@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+type (
+	// Statistics is what admin dashboard shows.
+	Statistics struct {
+		Subscribers  SubscriberCounts
+		Breakdown    []SubscriptionGroup
+		TopCities    []CityCount
+		Deliveries   []DeliveryCount
+		WeatherUsage WeatherUsage
+	}
+
+	SubscriberCounts struct {
+		Total     int64
+		Confirmed int64
+		Pending   int64
+	}
+
+	// SubscriptionGroup counts subscriptions of one city with one frequency.
+	SubscriptionGroup struct {
+		City      string
+		Frequency string
+		Total     int64
+		Confirmed int64
+	}
+
+	// FrequencyCount counts confirmed subscribers of one frequency.
+	FrequencyCount struct {
+		Frequency string
+		Confirmed int64
+	}
+
+	CityCount struct {
+		City        string
+		Subscribers int64
+	}
+
+	// DeliveryCount counts letters of one kind sent during one day.
+	DeliveryCount struct {
+		Day    time.Time
+		Kind   string
+		Sent   int64
+		Failed int64
+	}
+
+	// WeatherUsage counts requests made to weather provider by this process
+	// during Month since Since, other processes are not included. Quota is
+	// zero when it is unknown.
+	WeatherUsage struct {
+		Month time.Time
+		Since time.Time
+		Calls int64
+		Quota int64
+	}
+)
+
+// Percent tells how much of quota is used, zero when quota is unknown.
+func (u WeatherUsage) Percent() int64 {
+	if u.Quota == 0 {
+		return 0
+	}
+	return u.Calls * 100 / u.Quota
+}
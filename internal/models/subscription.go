@@ -2,18 +2,42 @@ package models
 
 import "time"
 
+const (
+	FrequencyHourly = "hourly"
+	FrequencyDaily  = "daily"
+)
+
+var Frequencies = []string{FrequencyHourly, FrequencyDaily}
+
 type (
 	Subscription struct {
 		ID        uint
-		Email     string `gorm:"unique" json:"email" form:"email"`
-		City      string `json:"city" form:"city"`
-		Frequency string `json:"period" form:"period"`
+		Email     string `gorm:"unique" json:"email" form:"email" binding:"required,max=255,rfc5322email"`
+		City      string `json:"city" form:"city" binding:"required,max=255"`
+		Frequency string `json:"frequency" form:"frequency" binding:"required,frequency"`
 		Confirmed bool
 		CreatedAt time.Time
 		UpdatedAt time.Time
 		Tokens    []Token
 	}
 
+	// SubscriptionFilter narrows subscription search, zero values match everything.
+	SubscriptionFilter struct {
+		City        string
+		Frequency   string
+		Confirmed   *bool
+		CreatedFrom *time.Time
+		CreatedTo   *time.Time
+		Offset      int
+		Limit       int
+	}
+
+	SubscriptionDetails struct {
+		Subscription Subscription
+		Tokens       []Token
+		Deliveries   []Delivery
+	}
+
 	Subscriber struct {
 		Recipient string
 		Period    string
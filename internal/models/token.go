@@ -2,12 +2,10 @@ package models
 
 import (
 	"time"
-
-	"github.com/google/uuid"
 )
 
 type Token struct {
-	ID             uuid.UUID `gorm:"primaryKey"`
+	Hash           string `gorm:"primaryKey;size:64"`
 	Expires        time.Time
 	SubscriptionID uint
 	CreatedAt      time.Time
@@ -0,0 +1,132 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Rabiann/weather-mailer/internal/logging"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/google/uuid"
+)
+
+type (
+	// LetterSink keeps letters rendered by dry run, name is unique within
+	// the run. Letters are written one at a time.
+	LetterSink interface {
+		WriteLetter(name string, letter []byte) error
+	}
+
+	// DirectorySink writes every letter into its own `.eml` file.
+	DirectorySink struct {
+		dir string
+	}
+
+	// StreamSink writes letters one after another, each preceded by its
+	// file name.
+	StreamSink struct {
+		w io.Writer
+	}
+
+	// DryRunSummary is what pipeline would have sent.
+	DryRunSummary struct {
+		Period Period
+		// Recipients are addresses letters were rendered for by city,
+		// written in lower case as weather is looked up.
+		Recipients map[string][]string
+		// Failed counts subscribers whose letters could not be rendered.
+		Failed int
+	}
+
+	dryRun struct {
+		mu      sync.Mutex
+		sink    LetterSink
+		summary DryRunSummary
+	}
+)
+
+func NewDirectorySink(dir string) (DirectorySink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return DirectorySink{}, err
+	}
+
+	return DirectorySink{dir: dir}, nil
+}
+
+func (s DirectorySink) WriteLetter(name string, letter []byte) error {
+	return os.WriteFile(filepath.Join(s.dir, name+".eml"), letter, 0o644)
+}
+
+func NewStreamSink(w io.Writer) StreamSink {
+	return StreamSink{w: w}
+}
+
+func (s StreamSink) WriteLetter(name string, letter []byte) error {
+	if _, err := fmt.Fprintf(s.w, "==> %s.eml <==\n", name); err != nil {
+		return err
+	}
+
+	_, err := s.w.Write(letter)
+	return err
+}
+
+// Letters is how many letters were rendered.
+func (s DryRunSummary) Letters() int {
+	letters := 0
+	for _, recipients := range s.Recipients {
+		letters += len(recipients)
+	}
+	return letters
+}
+
+// DryRun goes through pipeline of period as Run does, but letters are
+// written to sink instead of being sent. No tokens or deliveries are
+// recorded and no notifier metrics are counted.
+func (n Notifier) DryRun(period Period, baseUrl string, sink LetterSink, ctx context.Context) (DryRunSummary, error) {
+	ctx = logging.With(ctx,
+		slog.String("run_id", uuid.NewString()),
+		slog.String("period", period.String()),
+		slog.Bool("dry_run", true),
+	)
+
+	dry := &dryRun{
+		sink:    sink,
+		summary: DryRunSummary{Period: period, Recipients: make(map[string][]string)},
+	}
+	err := n.runPipeline(period, baseUrl, dry, ctx)
+
+	return dry.summary, err
+}
+
+func (d *dryRun) render(mailingService MailingService, subscriber *models.Subscriber, weather *models.Weather, unsubscribingUrl string, name string) error {
+	var letter bytes.Buffer
+	if err := mailingService.RenderWeatherReport(subscriber, weather, unsubscribingUrl, &letter); err != nil {
+		d.fail()
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.sink.WriteLetter(name, letter.Bytes()); err != nil {
+		d.summary.Failed++
+		return err
+	}
+
+	city := strings.ToLower(subscriber.City)
+	d.summary.Recipients[city] = append(d.summary.Recipients[city], subscriber.Recipient)
+	return nil
+}
+
+func (d *dryRun) fail() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.summary.Failed++
+}
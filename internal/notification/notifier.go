@@ -2,14 +2,23 @@ package notification
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Rabiann/weather-mailer/internal/logging"
+	"github.com/Rabiann/weather-mailer/internal/metrics"
 	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/tracing"
 	"github.com/go-co-op/gocron/v2"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const Day = time.Hour * 24
@@ -70,10 +79,30 @@ type (
 		subscriptionService SubscriptionService
 		mailingService      MailingService
 		tokenService        TokenService
+		deliveryRecorder    DeliveryRecorder
+		// concurrency is shared by copies of Notifier, so that it can be
+		// changed while scheduler runs
+		concurrency *atomic.Int64
+		logger      *slog.Logger
+		state       *schedulerState
+	}
+
+	// schedulerState is what health checks know about scheduler, it is
+	// shared by copies of Notifier.
+	schedulerState struct {
+		mu          sync.Mutex
+		startedAt   time.Time
+		lastSuccess map[Period]time.Time
+		now         func() time.Time
 	}
 
 	MailingService interface {
-		SendWeatherReport(*models.Subscriber, *models.Weather, string) error
+		SendWeatherReport(*models.Subscriber, *models.Weather, string, context.Context) error
+		RenderWeatherReport(*models.Subscriber, *models.Weather, string, io.Writer) error
+	}
+
+	DeliveryRecorder interface {
+		RecordDelivery(uint, string, string, error, context.Context) error
 	}
 
 	TokenService interface {
@@ -89,13 +118,122 @@ type (
 	}
 )
 
-func NewNotifier(weatherService WeatherService, subscriptionService SubscriptionService, mailingService MailingService, tokenService TokenService) Notifier {
-	return Notifier{
+func NewNotifier(weatherService WeatherService, subscriptionService SubscriptionService, mailingService MailingService, tokenService TokenService, deliveryRecorder DeliveryRecorder, concurrency int, logger *slog.Logger) Notifier {
+	n := Notifier{
 		weatherService:      weatherService,
 		subscriptionService: subscriptionService,
 		mailingService:      mailingService,
 		tokenService:        tokenService,
+		deliveryRecorder:    deliveryRecorder,
+		concurrency:         new(atomic.Int64),
+		logger:              logger,
+		state:               &schedulerState{lastSuccess: make(map[Period]time.Time), now: time.Now},
+	}
+	n.SetConcurrency(concurrency)
+
+	return n
+}
+
+// SetConcurrency changes how many letters are sent at once, starting from
+// next run.
+func (n Notifier) SetConcurrency(concurrency int) {
+	n.concurrency.Store(int64(concurrency))
+}
+
+// Interval is how often pipeline of period runs.
+func (p Period) Interval() time.Duration {
+	if p == Daily {
+		return Day
+	}
+	return time.Hour
+}
+
+func (p Period) String() string {
+	if p == Daily {
+		return models.FrequencyDaily
+	}
+	return models.FrequencyHourly
+}
+
+// ParsePeriod reads period from its frequency name.
+func ParsePeriod(name string) (Period, error) {
+	switch name {
+	case models.FrequencyDaily:
+		return Daily, nil
+	case models.FrequencyHourly:
+		return Hourly, nil
+	default:
+		return 0, fmt.Errorf("period should be `%s` or `%s`, got `%s`", models.FrequencyDaily, models.FrequencyHourly, name)
+	}
+}
+
+// runScheduled runs pipeline of period for scheduler, which has nowhere to
+// return error to, so it is only logged.
+func (n Notifier) runScheduled(period Period, baseUrl string) {
+	_ = n.Run(period, baseUrl, context.Background())
+}
+
+// Run runs pipeline of period once under its own run ID, which every log
+// record of the run carries.
+func (n Notifier) Run(period Period, baseUrl string, ctx context.Context) error {
+	ctx = logging.With(ctx,
+		slog.String("run_id", uuid.NewString()),
+		slog.String("period", period.String()),
+	)
+
+	ctx, span := tracing.Tracer.Start(ctx, "Notifier.run", trace.WithNewRoot(), trace.WithAttributes(attribute.String("notifier.period", period.String())))
+
+	start := time.Now()
+	n.logger.InfoContext(ctx, "notifier run started")
+	err := n.RunSendingPipeline(period, baseUrl, ctx)
+	tracing.End(span, err)
+	metrics.NotifierRunDuration.WithLabelValues(period.String(), metrics.Outcome(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		n.logger.ErrorContext(ctx, "notifier run failed", slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+		return err
+	}
+	n.logger.InfoContext(ctx, "notifier run finished", slog.Duration("duration", time.Since(start)))
+
+	n.state.mu.Lock()
+	n.state.lastSuccess[period] = n.state.now()
+	n.state.mu.Unlock()
+
+	return nil
+}
+
+// CheckScheduler tells whether scheduler is started.
+func (n Notifier) CheckScheduler(context.Context) (map[string]any, error) {
+	n.state.mu.Lock()
+	defer n.state.mu.Unlock()
+
+	if n.state.startedAt.IsZero() {
+		return nil, errors.New("scheduler is not running")
 	}
+
+	return map[string]any{"startedAt": n.state.startedAt.UTC()}, nil
+}
+
+// CheckRuns fails when pipeline of some period has not succeeded for two
+// of its intervals, counting from start when it has not succeeded yet.
+func (n Notifier) CheckRuns(context.Context) (map[string]any, error) {
+	n.state.mu.Lock()
+	defer n.state.mu.Unlock()
+
+	details := make(map[string]any)
+	var err error
+	for _, period := range []Period{Hourly, Daily} {
+		since := n.state.startedAt
+		if last, ok := n.state.lastSuccess[period]; ok {
+			since = last
+			details[period.String()+"LastSuccess"] = last.UTC()
+		}
+
+		if !since.IsZero() && n.state.now().Sub(since) > 2*period.Interval() && err == nil {
+			err = fmt.Errorf("%s run has not succeeded since %s", period, since.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return details, err
 }
 
 func (n Notifier) RunNotifier(baseUrl string) {
@@ -106,10 +244,10 @@ func (n Notifier) RunNotifier(baseUrl string) {
 
 	_, err = s.NewJob(
 		gocron.DurationJob(
-			Day,
+			Daily.Interval(),
 		),
 		gocron.NewTask(
-			n.RunSendingPipeline,
+			n.runScheduled,
 			Daily,
 			baseUrl,
 		),
@@ -121,10 +259,10 @@ func (n Notifier) RunNotifier(baseUrl string) {
 
 	_, err = s.NewJob(
 		gocron.DurationJob(
-			time.Hour,
+			Hourly.Interval(),
 		),
 		gocron.NewTask(
-			n.RunSendingPipeline,
+			n.runScheduled,
 			Hourly,
 			baseUrl,
 		),
@@ -136,59 +274,114 @@ func (n Notifier) RunNotifier(baseUrl string) {
 
 	s.Start()
 
+	n.state.mu.Lock()
+	n.state.startedAt = n.state.now()
+	n.state.mu.Unlock()
+
 	// block thread, run scheduler infinitely
 	select {}
 }
 
 func (n Notifier) RunSendingPipeline(period Period, baseUrl string, ctx_ context.Context) error {
-	var per string
-	var err error
+	return n.runPipeline(period, baseUrl, nil, ctx_)
+}
 
-	cache := NewAsyncCache()
-	semaphore := NewSemaphore(10)
+// runPipeline sends reports of period to every active subscriber, or
+// renders them into dry run when it is not nil.
+func (n Notifier) runPipeline(period Period, baseUrl string, dry *dryRun, ctx_ context.Context) error {
+	var wg sync.WaitGroup
 
-	if period == Daily {
-		per = "daily"
-	} else {
-		per = "hourly"
-	}
+	cache := NewAsyncCache()
+	semaphore := NewSemaphore(int(n.concurrency.Load()))
+	per := period.String()
 
 	subscribers, err := n.subscriptionService.GetActiveSubscriptions(per, ctx_)
 	if err != nil {
 		return err
 	}
+	n.logger.InfoContext(ctx_, "sending weather reports", slog.Int("subscribers", len(subscribers)))
 
 	for _, sub := range subscribers {
 		semaphore.Acquire()
-		go func(models.Subscription) {
+		wg.Add(1)
+		go func(sub models.Subscription) {
+			defer wg.Done()
 			defer semaphore.Release()
+			logger := n.logger.With(slog.Uint64("subscription_id", uint64(sub.ID)))
+
+			ctx, span := tracing.Tracer.Start(ctx_, "Notifier.send", trace.WithAttributes(
+				attribute.Int64("subscription.id", int64(sub.ID)),
+				attribute.String("subscription.city", sub.City),
+			))
+			var err error
+			defer func() { tracing.End(span, err) }()
+
 			city := strings.ToLower(sub.City)
 			weather, ok := cache.Read(city)
 
+			// dry run leaves metrics of real runs alone
+			if dry == nil {
+				lookup := metrics.CacheMiss
+				if ok {
+					lookup = metrics.CacheHit
+				}
+				metrics.WeatherCacheLookups.WithLabelValues(lookup).Inc()
+			}
+
 			if !ok {
-				weather, err = n.weatherService.GetWeather(city, ctx_)
+				weather, err = n.weatherService.GetWeather(city, ctx)
 				if err != nil {
+					if dry != nil {
+						dry.fail()
+					} else {
+						metrics.NotifierSubscribers.WithLabelValues(per, metrics.OutcomeError).Inc()
+					}
+					logger.ErrorContext(ctx, "weather report not sent", slog.String("city", sub.City), slog.Any("error", err))
 					return
 				}
 
-				cache.Write(sub.City, weather)
+				cache.Write(city, weather)
+			}
+
+			subscriber := models.Subscriber{
+				Recipient: sub.Email,
+				Period:    per,
+				City:      sub.City,
+			}
+
+			// dry run creates no tokens, so its letters carry link of none
+			if dry != nil {
+				url := fmt.Sprintf("%s/api/unsubscribe/%s", baseUrl, uuid.Nil)
+				if err = dry.render(n.mailingService, &subscriber, &weather, url, fmt.Sprintf("%s-%d", per, sub.ID)); err != nil {
+					logger.ErrorContext(ctx, "weather report not rendered", slog.String("city", sub.City), slog.Any("error", err))
+				}
+				return
 			}
 
-			token, err := n.tokenService.CreateToken(sub.ID, ctx_)
+			var token uuid.UUID
+			token, err = n.tokenService.CreateToken(sub.ID, ctx)
 			if err != nil {
+				metrics.NotifierSubscribers.WithLabelValues(per, metrics.OutcomeError).Inc()
+				logger.ErrorContext(ctx, "weather report not sent", slog.String("city", sub.City), slog.Any("error", err))
 				return
 			}
 
 			url := fmt.Sprintf("%s/api/unsubscribe/%s", baseUrl, token)
 
-			sub := models.Subscriber{
-				Recipient: sub.Email,
-				Period:    per,
-				City:      sub.City,
+			err = n.mailingService.SendWeatherReport(&subscriber, &weather, url, ctx)
+			metrics.NotifierSubscribers.WithLabelValues(per, metrics.Outcome(err)).Inc()
+			if err != nil {
+				logger.ErrorContext(ctx, "weather report not sent", slog.String("city", sub.City), slog.Any("error", err))
+			} else {
+				logger.InfoContext(ctx, "weather report sent", slog.String("city", sub.City))
+			}
+
+			if recordErr := n.deliveryRecorder.RecordDelivery(sub.ID, models.DeliveryWeather, sub.Email, err, ctx); recordErr != nil {
+				logger.ErrorContext(ctx, "delivery not recorded", slog.Any("error", recordErr))
 			}
-			_ = n.mailingService.SendWeatherReport(&sub, &weather, url)
 		}(sub)
 	}
 
+	wg.Wait()
 	return nil
 }
@@ -0,0 +1,259 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/metrics"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type (
+	stubWeather struct{}
+
+	stubSubscriptions []models.Subscription
+
+	// recordingServices count what pipeline did with side effects, letters
+	// are rendered as recipient and link.
+	recordingServices struct {
+		mu         sync.Mutex
+		tokens     int
+		sent       int
+		deliveries int
+	}
+
+	// memorySink keeps letters by name.
+	memorySink map[string]string
+)
+
+func (stubWeather) GetWeather(city string, _ context.Context) (models.Weather, error) {
+	if city == "atlantis" {
+		return models.Weather{}, errors.New("city not found")
+	}
+	return models.Weather{Temperature: 21.5, Humidity: 40, Description: "Sunny"}, nil
+}
+
+func (s stubSubscriptions) GetActiveSubscriptions(per string, _ context.Context) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	for _, subscription := range s {
+		if subscription.Frequency == per {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (r *recordingServices) CreateToken(uint, context.Context) (uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens++
+	return uuid.New(), nil
+}
+
+func (r *recordingServices) SendWeatherReport(*models.Subscriber, *models.Weather, string, context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent++
+	return nil
+}
+
+func (r *recordingServices) RenderWeatherReport(subscriber *models.Subscriber, _ *models.Weather, unsubscribingUrl string, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s %s", subscriber.Recipient, unsubscribingUrl)
+	return err
+}
+
+func (r *recordingServices) RecordDelivery(uint, string, string, error, context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries++
+	return nil
+}
+
+func (s memorySink) WriteLetter(name string, letter []byte) error {
+	s[name] = string(bytes.Clone(letter))
+	return nil
+}
+
+func newTestNotifier(subscriptions stubSubscriptions) (Notifier, *recordingServices) {
+	services := &recordingServices{}
+	return NewNotifier(stubWeather{}, subscriptions, services, services, services, 2, testLogger), services
+}
+
+// notifierMetrics sums counters notifier runs change.
+func notifierMetrics() float64 {
+	sum := 0.0
+	for _, lookup := range []string{metrics.CacheHit, metrics.CacheMiss} {
+		sum += testutil.ToFloat64(metrics.WeatherCacheLookups.WithLabelValues(lookup))
+	}
+	for _, outcome := range []string{metrics.OutcomeSuccess, metrics.OutcomeError} {
+		sum += testutil.ToFloat64(metrics.NotifierSubscribers.WithLabelValues(models.FrequencyDaily, outcome))
+	}
+	return sum
+}
+
+func TestDryRunHasNoSideEffects(t *testing.T) {
+	notifier, services := newTestNotifier(stubSubscriptions{
+		{ID: 1, Email: "first@example.com", City: "Kyiv", Frequency: models.FrequencyDaily, Confirmed: true},
+		{ID: 2, Email: "second@example.com", City: "kyiv", Frequency: models.FrequencyDaily, Confirmed: true},
+		{ID: 3, Email: "third@example.com", City: "Atlantis", Frequency: models.FrequencyDaily, Confirmed: true},
+		{ID: 4, Email: "hourly@example.com", City: "Lviv", Frequency: models.FrequencyHourly, Confirmed: true},
+	})
+	sink := memorySink{}
+	before := notifierMetrics()
+
+	summary, err := notifier.DryRun(Daily, "http://localhost:8000", sink, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if services.tokens != 0 || services.deliveries != 0 || services.sent != 0 {
+		t.Errorf("dry run created %d tokens, recorded %d deliveries and sent %d letters", services.tokens, services.deliveries, services.sent)
+	}
+	if after := notifierMetrics(); after != before {
+		t.Errorf("dry run changed notifier metrics by %v", after-before)
+	}
+
+	if summary.Letters() != 2 || summary.Failed != 1 || len(summary.Recipients["kyiv"]) != 2 {
+		t.Errorf("summary is %+v", summary)
+	}
+	want := "first@example.com http://localhost:8000/api/unsubscribe/" + uuid.Nil.String()
+	if sink["daily-1"] != want {
+		t.Errorf("letter is %q, want %q", sink["daily-1"], want)
+	}
+}
+
+// Guards the test above against passing only because pipeline does nothing.
+func TestRunSendsReports(t *testing.T) {
+	notifier, services := newTestNotifier(stubSubscriptions{
+		{ID: 1, Email: "first@example.com", City: "Kyiv", Frequency: models.FrequencyDaily, Confirmed: true},
+		{ID: 2, Email: "second@example.com", City: "kyiv", Frequency: models.FrequencyDaily, Confirmed: true},
+	})
+	before := notifierMetrics()
+
+	if err := notifier.RunSendingPipeline(Daily, "http://localhost:8000", context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if services.tokens != 2 || services.deliveries != 2 || services.sent != 2 {
+		t.Errorf("run created %d tokens, recorded %d deliveries and sent %d letters, want 2 of each", services.tokens, services.deliveries, services.sent)
+	}
+	// two cache lookups and two processed subscribers
+	if after := notifierMetrics(); after-before != 4 {
+		t.Errorf("run changed notifier metrics by %v, want 4", after-before)
+	}
+}
+
+func TestCheckScheduler(t *testing.T) {
+	notifier, _ := newTestNotifier(nil)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	notifier.state.now = func() time.Time { return now }
+
+	if _, err := notifier.CheckScheduler(context.Background()); err == nil {
+		t.Error("scheduler which was not started is healthy")
+	}
+
+	notifier.state.startedAt = now
+	details, err := notifier.CheckScheduler(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details["startedAt"] != now {
+		t.Errorf("details are %v", details)
+	}
+}
+
+// Pipeline of period fails its check when it has not succeeded for two of
+// its intervals, counting from start of scheduler until it succeeds once.
+func TestCheckRuns(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	tests := []struct {
+		name        string
+		startedAt   time.Time
+		lastSuccess map[Period]time.Time
+		failing     string
+	}{
+		{name: "scheduler not started"},
+		{name: "just started", startedAt: ago(time.Minute)},
+		{name: "started two hours ago", startedAt: ago(2 * time.Hour)},
+		{name: "hourly never succeeded", startedAt: ago(2*time.Hour + time.Second), failing: "hourly"},
+		{
+			name:        "both succeeded recently",
+			startedAt:   ago(72 * time.Hour),
+			lastSuccess: map[Period]time.Time{Hourly: ago(time.Hour), Daily: ago(30 * time.Hour)},
+		},
+		{
+			name:        "hourly missed two runs",
+			startedAt:   ago(72 * time.Hour),
+			lastSuccess: map[Period]time.Time{Hourly: ago(2*time.Hour + time.Minute), Daily: ago(time.Hour)},
+			failing:     "hourly",
+		},
+		{
+			name:        "daily missed two runs",
+			startedAt:   ago(72 * time.Hour),
+			lastSuccess: map[Period]time.Time{Hourly: ago(time.Hour), Daily: ago(48*time.Hour + time.Minute)},
+			failing:     "daily",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notifier, _ := newTestNotifier(nil)
+			notifier.state.now = func() time.Time { return now }
+			notifier.state.startedAt = test.startedAt
+			for period, last := range test.lastSuccess {
+				notifier.state.lastSuccess[period] = last
+			}
+
+			details, err := notifier.CheckRuns(context.Background())
+			if test.failing == "" && err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			if test.failing != "" && (err == nil || !strings.HasPrefix(err.Error(), test.failing)) {
+				t.Fatalf("got %v, want failure of %s run", err, test.failing)
+			}
+
+			for period, last := range test.lastSuccess {
+				if details[period.String()+"LastSuccess"] != last {
+					t.Errorf("details are %v", details)
+				}
+			}
+		})
+	}
+}
+
+func TestRunRecordsSuccess(t *testing.T) {
+	notifier, _ := newTestNotifier(stubSubscriptions{
+		{ID: 1, Email: "first@example.com", City: "Kyiv", Frequency: models.FrequencyHourly, Confirmed: true},
+	})
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	notifier.state.now = func() time.Time { return now }
+	notifier.state.startedAt = now.Add(-3 * time.Hour)
+
+	if _, err := notifier.CheckRuns(context.Background()); err == nil {
+		t.Fatal("hourly run which never succeeded is healthy")
+	}
+
+	if err := notifier.Run(Hourly, "http://localhost:8000", context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notifier.CheckRuns(context.Background()); err != nil {
+		t.Errorf("check fails after successful run: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package persistance
+
+import (
+	"context"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"gorm.io/gorm"
+)
+
+const countApiKeyUsage = `INSERT INTO api_key_usage (api_key_id, day, count) VALUES (?, ?, 1)
+ON CONFLICT (api_key_id, day) DO UPDATE SET count = api_key_usage.count + 1
+RETURNING count`
+
+var ErrApiKeyNotFound = apperrors.New(apperrors.NotFound, "API key not found")
+
+type (
+	ApiKeyRepository struct {
+		Db *gorm.DB
+	}
+)
+
+func NewApiKeyRepository(db *gorm.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{db}
+}
+
+func (a *ApiKeyRepository) AddKey(key models.ApiKey, ctx context.Context) (models.ApiKey, error) {
+	result := connection(a.Db, ctx).Create(&key)
+	return key, result.Error
+}
+
+func (a *ApiKeyRepository) GetKeys(ctx context.Context) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+	result := connection(a.Db, ctx).Order("id").Find(&keys)
+	return keys, result.Error
+}
+
+func (a *ApiKeyRepository) GetKeyByHash(hash string, ctx context.Context) (models.ApiKey, error) {
+	var key models.ApiKey
+	result := connection(a.Db, ctx).Where("hash = ?", hash).First(&key)
+	return key, translateError(result.Error, ErrApiKeyNotFound, nil)
+}
+
+// RevokeKey revokes active key, keys already revoked are not found.
+func (a *ApiKeyRepository) RevokeKey(id uint, ctx context.Context) error {
+	result := connection(a.Db, ctx).Model(&models.ApiKey{ID: id}).Where("revoked_at IS NULL").Update("revoked_at", time.Now())
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrApiKeyNotFound
+	}
+
+	return result.Error
+}
+
+// CountUsage counts request made with key and returns number of requests
+// made with it during day, this one included.
+func (a *ApiKeyRepository) CountUsage(id uint, day time.Time, ctx context.Context) (int, error) {
+	var count int
+
+	err := connection(a.Db, ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(countApiKeyUsage, id, day).Scan(&count).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.ApiKey{ID: id}).UpdateColumns(map[string]any{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": time.Now(),
+		}).Error
+	})
+
+	return count, err
+}
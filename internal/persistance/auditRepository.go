@@ -0,0 +1,34 @@
+package persistance
+
+import (
+	"context"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"gorm.io/gorm"
+)
+
+type (
+	AuditRepository struct {
+		Db *gorm.DB
+	}
+)
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+func (a *AuditRepository) AddRecord(record models.AuditRecord, ctx context.Context) error {
+	result := connection(a.Db, ctx).Create(&record)
+	return result.Error
+}
+
+func (a *AuditRepository) GetRecords(offset int, limit int, ctx context.Context) ([]models.AuditRecord, int64, error) {
+	var total int64
+	if result := connection(a.Db, ctx).Model(&models.AuditRecord{}).Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var records []models.AuditRecord
+	result := connection(a.Db, ctx).Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&records)
+	return records, total, result.Error
+}
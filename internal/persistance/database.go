@@ -2,8 +2,9 @@ package persistance
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
+	"github.com/Rabiann/weather-mailer/internal/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -12,40 +13,62 @@ type ConnectionString struct {
 	User     string
 	Password string
 	DbName   string
-	Port     string
+	Port     int
 	Host     string
+	SslMode  string
+	Url      string
 }
 
-func NewConnectionString() ConnectionString {
-	cs := ConnectionString{}
-	cs.User = os.Getenv("POSTGRES_USER")
-	cs.Password = os.Getenv("POSTGRES_PASSWORD")
-	cs.DbName = os.Getenv("POSTGRES_DB")
-	cs.Host = os.Getenv("POSTGRES_HOST")
-
-	return cs
+func NewConnectionString(database config.Database) ConnectionString {
+	return ConnectionString{
+		User:     database.User,
+		Password: database.Password,
+		DbName:   database.Name,
+		Port:     database.Port,
+		Host:     database.Host,
+		SslMode:  database.SslMode,
+		Url:      database.Url,
+	}
 }
 
 func (c ConnectionString) GetConnectionString() string {
-	is_prod := os.Getenv("PROD")
-	if is_prod == "1" {
-		db_url := os.Getenv("PROD_DB_URL")
-		if db_url == "" {
-			panic("PROD_DB_URL not set")
-		}
-
-		return db_url
+	if c.Url != "" {
+		return c.Url
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s", quote(c.Host), c.Port, quote(c.User), quote(c.Password), quote(c.DbName))
+	if c.SslMode != "" {
+		dsn += " sslmode=" + quote(c.SslMode)
 	}
 
-	return fmt.Sprintf("host=%s port=5432 user=%s password=%s dbname=%s", c.Host, c.User, c.Password, c.DbName)
+	return dsn
+}
+
+// quote makes value safe for key=value connection string, where spaces
+// and quotes would end it.
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
-func ConnectToDatabase() *gorm.DB {
-	cs := NewConnectionString()
-	db, err := gorm.Open(postgres.Open(cs.GetConnectionString()), &gorm.Config{})
+func ConnectToDatabase(database config.Database) (*gorm.DB, error) {
+	cs := NewConnectionString(database)
+	db, err := gorm.Open(postgres.Open(cs.GetConnectionString()), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Use(Tracing{}); err != nil {
+		return nil, err
+	}
+
+	sqlDb, err := db.DB()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	sqlDb.SetMaxOpenConns(database.Pool.MaxOpenConns)
+	sqlDb.SetMaxIdleConns(database.Pool.MaxIdleConns)
+	sqlDb.SetConnMaxLifetime(database.Pool.ConnMaxLifetime)
+	sqlDb.SetConnMaxIdleTime(database.Pool.ConnMaxIdleTime)
 
-	return db
+	return db, nil
 }
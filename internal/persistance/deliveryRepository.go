@@ -0,0 +1,30 @@
+package persistance
+
+import (
+	"context"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"gorm.io/gorm"
+)
+
+type (
+	DeliveryRepository struct {
+		Db *gorm.DB
+	}
+)
+
+func NewDeliveryRepository(db *gorm.DB) *DeliveryRepository {
+	return &DeliveryRepository{db}
+}
+
+func (d *DeliveryRepository) AddDelivery(delivery models.Delivery, ctx context.Context) error {
+	result := connection(d.Db, ctx).Create(&delivery)
+	return result.Error
+}
+
+func (d *DeliveryRepository) GetDeliveriesOfSubscription(subscriptionId uint, limit int, ctx context.Context) ([]models.Delivery, error) {
+	var deliveries []models.Delivery
+
+	result := connection(d.Db, ctx).Where("subscription_id = ?", subscriptionId).Order("created_at DESC").Limit(limit).Find(&deliveries)
+	return deliveries, result.Error
+}
@@ -0,0 +1,33 @@
+package persistance
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type HealthRepository struct {
+	Db *gorm.DB
+}
+
+func NewHealthRepository(db *gorm.DB) *HealthRepository {
+	return &HealthRepository{db}
+}
+
+// CheckHealth pings database and reports usage of connection pool.
+func (h *HealthRepository) CheckHealth(ctx context.Context) (map[string]any, error) {
+	sqlDb, err := h.Db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sqlDb.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	stats := sqlDb.Stats()
+	return map[string]any{
+		"openConnections": stats.OpenConnections,
+		"inUse":           stats.InUse,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package persistance
+
+import (
+	"context"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrOutboxMessageNotFound = apperrors.New(apperrors.NotFound, "outbox message not found")
+
+type (
+	OutboxRepository struct {
+		Db *gorm.DB
+	}
+)
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db}
+}
+
+func (o *OutboxRepository) AddMessage(message models.OutboxMessage, ctx context.Context) (uint, error) {
+	result := connection(o.Db, ctx).Create(&message)
+	return message.ID, result.Error
+}
+
+// DeletePending deletes messages of given kind not sent to subscription yet.
+func (o *OutboxRepository) DeletePending(subscriptionId uint, kind string, ctx context.Context) error {
+	result := connection(o.Db, ctx).
+		Where("subscription_id = ? AND kind = ? AND sent_at IS NULL", subscriptionId, kind).
+		Delete(&models.OutboxMessage{})
+	return result.Error
+}
+
+// ClaimMessage claims message which is not sent yet until given time, so
+// that nobody else sends it meanwhile. Claim is a single statement, it holds
+// no lock while letter is sent. Messages sent or claimed by someone else are
+// not found.
+func (o *OutboxRepository) ClaimMessage(id uint, until time.Time, ctx context.Context) (models.OutboxMessage, error) {
+	var message models.OutboxMessage
+	result := connection(o.Db, ctx).
+		Model(&message).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND sent_at IS NULL AND (claimed_until IS NULL OR claimed_until < ?)", id, time.Now()).
+		Update("claimed_until", until)
+	if result.Error != nil {
+		return message, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return message, ErrOutboxMessageNotFound
+	}
+	return message, nil
+}
+
+// GetPendingMessages lists up to limit unclaimed messages due for retry.
+// Each of them is still to be claimed before it is sent.
+func (o *OutboxRepository) GetPendingMessages(createdBefore time.Time, maxAttempts int, limit int, ctx context.Context) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	result := connection(o.Db, ctx).
+		Where("sent_at IS NULL AND attempts < ? AND created_at < ? AND (claimed_until IS NULL OR claimed_until < ?)", maxAttempts, createdBefore, time.Now()).
+		Order("id").
+		Limit(limit).
+		Find(&messages)
+	return messages, result.Error
+}
+
+func (o *OutboxRepository) MarkSent(id uint, ctx context.Context) error {
+	result := connection(o.Db, ctx).Model(&models.OutboxMessage{ID: id}).Updates(map[string]any{
+		"sent_at":       time.Now(),
+		"claimed_until": nil,
+	})
+	return result.Error
+}
+
+func (o *OutboxRepository) MarkFailed(id uint, reason string, ctx context.Context) error {
+	result := connection(o.Db, ctx).Model(&models.OutboxMessage{ID: id}).Updates(map[string]any{
+		"attempts":      gorm.Expr("attempts + 1"),
+		"last_error":    reason,
+		"claimed_until": nil,
+	})
+	return result.Error
+}
@@ -0,0 +1,54 @@
+package persistance
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+const hitRateLimit = `INSERT INTO rate_limits (key, window_end, count) VALUES (?, ?, 1)
+ON CONFLICT (key) DO UPDATE SET
+    count = CASE WHEN rate_limits.window_end = EXCLUDED.window_end THEN rate_limits.count + 1 ELSE 1 END,
+    window_end = EXCLUDED.window_end
+RETURNING count`
+
+type (
+	// RateLimitRepository is rate limit store shared by all instances of server.
+	RateLimitRepository struct {
+		Db *gorm.DB
+	}
+)
+
+func NewRateLimitRepository(db *gorm.DB) *RateLimitRepository {
+	return &RateLimitRepository{db}
+}
+
+func (r *RateLimitRepository) Hit(key string, window time.Duration, ctx context.Context) (int, time.Time, error) {
+	end := ratelimit.WindowStart(time.Now().UTC(), window).Add(window)
+
+	var count int
+	result := connection(r.Db, ctx).Raw(hitRateLimit, key, end).Scan(&count)
+	return count, end, result.Error
+}
+
+// DeleteExpired forgets counters of ended windows.
+func (r *RateLimitRepository) DeleteExpired(ctx context.Context) error {
+	result := connection(r.Db, ctx).Exec("DELETE FROM rate_limits WHERE window_end <= ?", time.Now().UTC())
+	return result.Error
+}
+
+// RunCleanup periodically deletes expired counters. It blocks, so it
+// should be started in own goroutine.
+func (r *RateLimitRepository) RunCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := r.DeleteExpired(context.Background()); err != nil {
+			slog.Error("rate limit cleanup failed", slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package persistance
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/migrations"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDatabaseEnv names database tests may create schemas in.
+const testDatabaseEnv = "TEST_DATABASE_URL"
+
+// testDatabase opens migrated schema of its own, dropped when test ends.
+func testDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("`%s` is not set", testDatabaseEnv)
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	// search_path is set on every connection of pool
+	if strings.Contains(dsn, "://") {
+		parsed, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := parsed.Query()
+		query.Set("search_path", schema)
+		parsed.RawQuery = query.Encode()
+		dsn = parsed.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestRateLimitRepositoryCountsHitsInWindow(t *testing.T) {
+	repository := NewRateLimitRepository(testDatabase(t))
+	ctx := context.Background()
+	window := time.Second
+
+	// start right after window begins, so that hits fall into one window
+	time.Sleep(time.Until(time.Now().Truncate(window).Add(window)))
+
+	var end time.Time
+	for want := 1; want <= 3; want++ {
+		count, reset, err := repository.Hit("subscribe", window, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Fatalf("hit %d counted as %d", want, count)
+		}
+		end = reset
+	}
+
+	count, _, err := repository.Hit("weather", window, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("other key counted as %d", count)
+	}
+
+	// counter starts over in the next window
+	time.Sleep(time.Until(end))
+	count, reset, err := repository.Hit("subscribe", window, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || !reset.Equal(end.Add(window)) {
+		t.Errorf("hit of next window counted as %d resetting at %s", count, reset)
+	}
+}
+
+// Instances share counters, so concurrent hits should not be lost.
+func TestRateLimitRepositoryCountsConcurrentHits(t *testing.T) {
+	repository := NewRateLimitRepository(testDatabase(t))
+	ctx := context.Background()
+
+	const hits = 20
+	var wg sync.WaitGroup
+	errs := make([]error, hits)
+	for i := range hits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = repository.Hit("key", time.Hour, ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, _, err := repository.Hit("key", time.Hour, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != hits+1 {
+		t.Errorf("%d hits counted, want %d", count-1, hits)
+	}
+}
+
+func TestRateLimitRepositoryDeletesExpired(t *testing.T) {
+	db := testDatabase(t)
+	repository := NewRateLimitRepository(db)
+	ctx := context.Background()
+
+	if _, _, err := repository.Hit("short", time.Millisecond, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repository.Hit("long", time.Hour, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := repository.DeleteExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	if err := db.Raw("SELECT key FROM rate_limits ORDER BY key").Scan(&keys).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "long" {
+		t.Errorf("counters left: %v, want only `long`", keys)
+	}
+}
@@ -0,0 +1,83 @@
+package persistance
+
+import (
+	"context"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"gorm.io/gorm"
+)
+
+type (
+	StatisticsRepository struct {
+		Db *gorm.DB
+	}
+)
+
+func NewStatisticsRepository(db *gorm.DB) *StatisticsRepository {
+	return &StatisticsRepository{db}
+}
+
+func (s *StatisticsRepository) CountSubscribers(ctx context.Context) (models.SubscriberCounts, error) {
+	var counts models.SubscriberCounts
+
+	result := connection(s.Db, ctx).Model(&models.Subscription{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE confirmed) AS confirmed").
+		Scan(&counts)
+	counts.Pending = counts.Total - counts.Confirmed
+
+	return counts, result.Error
+}
+
+func (s *StatisticsRepository) GroupSubscriptions(ctx context.Context) ([]models.SubscriptionGroup, error) {
+	var groups []models.SubscriptionGroup
+
+	result := connection(s.Db, ctx).Model(&models.Subscription{}).
+		Select("city, frequency, COUNT(*) AS total, COUNT(*) FILTER (WHERE confirmed) AS confirmed").
+		Group("city, frequency").
+		Order("city, frequency").
+		Scan(&groups)
+
+	return groups, result.Error
+}
+
+func (s *StatisticsRepository) CountByFrequency(ctx context.Context) ([]models.FrequencyCount, error) {
+	var counts []models.FrequencyCount
+
+	result := connection(s.Db, ctx).Model(&models.Subscription{}).
+		Select("frequency, COUNT(*) AS confirmed").
+		Where("confirmed").
+		Group("frequency").
+		Scan(&counts)
+
+	return counts, result.Error
+}
+
+// TopCities returns cities with the most confirmed subscribers.
+func (s *StatisticsRepository) TopCities(limit int, ctx context.Context) ([]models.CityCount, error) {
+	var cities []models.CityCount
+
+	result := connection(s.Db, ctx).Model(&models.Subscription{}).
+		Select("city, COUNT(*) AS subscribers").
+		Where("confirmed").
+		Group("city").
+		Order("subscribers DESC, city").
+		Limit(limit).
+		Scan(&cities)
+
+	return cities, result.Error
+}
+
+// CountDeliveries counts sent and failed letters per day and kind since given time.
+func (s *StatisticsRepository) CountDeliveries(since time.Time, ctx context.Context) ([]models.DeliveryCount, error) {
+	var counts []models.DeliveryCount
+
+	result := connection(s.Db, ctx).Model(&models.Delivery{}).
+		Select("DATE_TRUNC('day', created_at) AS day, kind, COUNT(*) FILTER (WHERE status = ?) AS sent, COUNT(*) FILTER (WHERE status = ?) AS failed", models.DeliverySent, models.DeliveryFailed).
+		Where("created_at >= ?", since).
+		Group("day, kind").
+		Order("day DESC, kind").
+		Scan(&counts)
+
+	return counts, result.Error
+}
@@ -4,10 +4,34 @@ import (
 	"context"
 	"errors"
 
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
 	"github.com/Rabiann/weather-mailer/internal/models"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrSubscriptionNotFound = apperrors.New(apperrors.NotFound, "subscription not found")
+	ErrEmailTaken           = apperrors.New(apperrors.Conflict, "email already subscribed")
+	ErrAlreadyConfirmed     = apperrors.New(apperrors.Conflict, "subscription already confirmed")
+)
+
+// translateError maps storage errors onto domain ones: missing record onto
+// notFound and violated unique constraint onto conflict. Nil conflict leaves
+// violations as they are, for statements which are not expected to cause
+// them.
+func translateError(err error, notFound error, conflict error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return notFound
+	case conflict != nil && errors.Is(err, gorm.ErrDuplicatedKey):
+		return conflict
+	default:
+		return err
+	}
+}
+
 type (
 	SubscriptionRepository struct {
 		Db *gorm.DB
@@ -20,45 +44,90 @@ func NewSubscriptionRepository(db *gorm.DB) *SubscriptionRepository {
 
 func (s *SubscriptionRepository) GetSubscriptions(ctx context.Context) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
-	result := s.Db.WithContext(ctx).Find(&subscriptions)
+	result := connection(s.Db, ctx).Find(&subscriptions)
 	return subscriptions, result.Error
 }
 
+// SearchSubscriptions returns page of subscriptions matching filter, newest
+// first, together with total number of matching ones.
+func (s *SubscriptionRepository) SearchSubscriptions(filter models.SubscriptionFilter, ctx context.Context) ([]models.Subscription, int64, error) {
+	query := connection(s.Db, ctx).Model(&models.Subscription{})
+
+	if filter.City != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", filter.City)
+	}
+	if filter.Frequency != "" {
+		query = query.Where("frequency = ?", filter.Frequency)
+	}
+	if filter.Confirmed != nil {
+		query = query.Where("confirmed = ?", *filter.Confirmed)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var subscriptions []models.Subscription
+	result := query.Order("created_at DESC, id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&subscriptions)
+	return subscriptions, total, result.Error
+}
+
 func (s *SubscriptionRepository) GetSubscriptionById(id uint, ctx context.Context) (models.Subscription, error) {
 	subscription := models.Subscription{ID: id}
-	result := s.Db.WithContext(ctx).First(&subscription)
-	return subscription, result.Error
+	result := connection(s.Db, ctx).First(&subscription)
+	return subscription, translateError(result.Error, ErrSubscriptionNotFound, nil)
+}
+
+func (s *SubscriptionRepository) GetSubscriptionByEmail(email string, ctx context.Context) (*models.Subscription, error) {
+	var subscriptions []models.Subscription
+	result := connection(s.Db, ctx).Where("email = ?", email).Limit(1).Find(&subscriptions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(subscriptions) == 0 {
+		return nil, nil
+	}
+
+	return &subscriptions[0], nil
 }
 
 func (s *SubscriptionRepository) AddSubscription(subscription models.Subscription, ctx context.Context) (uint, error) {
 	if s.Db == nil {
 		return 0, nil
 	}
-	result := s.Db.WithContext(ctx).Create(&subscription)
-	return subscription.ID, result.Error
+	result := connection(s.Db, ctx).Create(&subscription)
+	return subscription.ID, translateError(result.Error, ErrSubscriptionNotFound, ErrEmailTaken)
 }
 
 func (s *SubscriptionRepository) ActivateSubscription(id uint, ctx context.Context) (string, error) {
 	var subscription models.Subscription
 	subscription.ID = id
 
-	result := s.Db.WithContext(ctx).Find(&subscription)
+	result := connection(s.Db, ctx).First(&subscription)
 	if result.Error != nil {
-		return "", result.Error
+		return "", translateError(result.Error, ErrSubscriptionNotFound, nil)
 	}
 
 	if subscription.Confirmed {
-		return "", errors.New("subscription already confirmed")
+		return "", ErrAlreadyConfirmed
 	}
 
 	subscription.Confirmed = true
-	result = s.Db.WithContext(ctx).Save(subscription)
+	result = connection(s.Db, ctx).Save(subscription)
 	return subscription.Email, result.Error
 }
 
 func (s *SubscriptionRepository) GetActiveSubscriptions(per string, ctx context.Context) ([]models.Subscription, error) {
 	var subscribers []models.Subscription
-	result := s.Db.WithContext(ctx).Where("frequency = ? and confirmed = true", per).Find(&subscribers)
+	result := connection(s.Db, ctx).Where("frequency = ? and confirmed = true", per).Find(&subscribers)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -71,13 +140,13 @@ func (s *SubscriptionRepository) UpdateSubscription(id uint, new_subscription mo
 	subscription := models.Subscription{ID: id}
 
 	if id != new_subscription.ID {
-		return errors.New("IDs differ")
+		return apperrors.New(apperrors.Validation, "IDs differ")
 	}
 
-	result := s.Db.WithContext(ctx).Find(&subscription)
+	result := connection(s.Db, ctx).First(&subscription)
 
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error, ErrSubscriptionNotFound, nil)
 	}
 
 	subscription.City = new_subscription.City
@@ -86,25 +155,38 @@ func (s *SubscriptionRepository) UpdateSubscription(id uint, new_subscription mo
 	subscription.Email = new_subscription.Email
 	subscription.Frequency = new_subscription.Frequency
 
-	result = s.Db.WithContext(ctx).Save(subscription)
-	return result.Error
+	result = connection(s.Db, ctx).Save(subscription)
+	return translateError(result.Error, ErrSubscriptionNotFound, ErrEmailTaken)
 }
 
 func (s *SubscriptionRepository) DeleteSubscription(id uint, ctx context.Context) error {
-	result := s.Db.WithContext(ctx).Delete(&models.Subscription{}, id)
+	result := connection(s.Db, ctx).Delete(&models.Subscription{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrSubscriptionNotFound
+	}
+
+	return result.Error
+}
+
+func (s *SubscriptionRepository) Deactivate(id uint, ctx context.Context) error {
+	result := connection(s.Db, ctx).Model(&models.Subscription{ID: id}).Update("confirmed", false)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrSubscriptionNotFound
+	}
+
 	return result.Error
 }
 
 func (s *SubscriptionRepository) Confirm(id uint, ctx context.Context) error {
 	subscription := models.Subscription{ID: id}
 
-	result := s.Db.WithContext(ctx).Find(&subscription)
+	result := connection(s.Db, ctx).First(&subscription)
 
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error, ErrSubscriptionNotFound, nil)
 	}
 
 	subscription.Confirmed = true
-	result = s.Db.WithContext(ctx).Save(subscription)
+	result = connection(s.Db, ctx).Save(subscription)
 	return result.Error
 }
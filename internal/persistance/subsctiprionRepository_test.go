@@ -0,0 +1,40 @@
+package persistance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	other := errors.New("connection lost")
+	duplicate := fmt.Errorf("insert: %w", gorm.ErrDuplicatedKey)
+
+	tests := []struct {
+		name     string
+		err      error
+		conflict error
+		want     error
+	}{
+		{"no error", nil, ErrEmailTaken, nil},
+		{"missing record", gorm.ErrRecordNotFound, nil, ErrTokenNotFound},
+		{"violation where conflict is expected", duplicate, ErrEmailTaken, ErrEmailTaken},
+		// token or key collision is not taken email
+		{"violation where none is expected", duplicate, nil, gorm.ErrDuplicatedKey},
+		{"other error", other, ErrEmailTaken, other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := translateError(test.err, ErrTokenNotFound, test.conflict)
+			if !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+			if test.conflict == nil && errors.Is(err, ErrEmailTaken) {
+				t.Error("violation is reported as taken email")
+			}
+		})
+	}
+}
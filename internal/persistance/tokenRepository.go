@@ -2,14 +2,22 @@ package persistance
 
 import (
 	"context"
-	"errors"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"time"
 
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
 	"github.com/Rabiann/weather-mailer/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrTokenNotFound = apperrors.New(apperrors.NotFound, "token not found")
+	ErrTokenExpired  = apperrors.New(apperrors.Expired, "token already expired")
+)
+
 type (
 	TokenRepository struct {
 		Db *gorm.DB
@@ -20,47 +28,77 @@ func NewTokenRepository(db *gorm.DB) *TokenRepository {
 	return &TokenRepository{db}
 }
 
+// HashToken returns hex-encoded SHA-256 of token. Only hashes are stored,
+// raw token leaves the process only inside emailed links.
+func HashToken(token uuid.UUID) string {
+	sum := sha256.Sum256([]byte(token.String()))
+	return hex.EncodeToString(sum[:])
+}
+
 func (t *TokenRepository) CreateToken(subscriptionId uint, ctx context.Context) (uuid.UUID, error) {
 	id := uuid.New()
 
 	token := models.Token{
-		ID:             id,
+		Hash:           HashToken(id),
 		SubscriptionID: subscriptionId,
 		Expires:        time.Now().Add(time.Hour * 24),
 	}
 
-	result := t.Db.WithContext(ctx).Create(&token)
+	result := connection(t.Db, ctx).Create(&token)
 	return id, result.Error
 }
 
+func (t *TokenRepository) GetToken(id uuid.UUID, ctx context.Context) (models.Token, error) {
+	var token models.Token
+
+	result := connection(t.Db, ctx).Where("hash = ?", HashToken(id)).First(&token)
+	return token, translateError(result.Error, ErrTokenNotFound, nil)
+}
+
 func (t *TokenRepository) GetSubscriptionOfToken(id uuid.UUID, ctx context.Context) (uint, error) {
 	var token models.Token
-	token.ID = id
 
-	result := t.Db.WithContext(ctx).Find(&token)
-	return token.SubscriptionID, result.Error
+	result := connection(t.Db, ctx).Where("hash = ?", HashToken(id)).First(&token)
+	return token.SubscriptionID, translateError(result.Error, ErrTokenNotFound, nil)
 }
 
 func (t *TokenRepository) UseToken(id uuid.UUID, ctx context.Context) error {
-	token := models.Token{
-		ID: id,
-	}
+	var token models.Token
 
-	result := t.Db.WithContext(ctx).First(&token)
+	result := connection(t.Db, ctx).Where("hash = ?", HashToken(id)).First(&token)
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error, ErrTokenNotFound, nil)
 	}
 
 	if time.Now().Compare(token.Expires) > 0 {
-		if result := t.Db.WithContext(ctx).Delete(token); result.Error != nil {
+		if result := connection(t.Db, ctx).Delete(&token); result.Error != nil {
 			return result.Error
 		}
-		return errors.New("token already expired")
+		return ErrTokenExpired
 	}
 
-	if result := t.Db.WithContext(ctx).Delete(token); result.Error != nil {
+	if result := connection(t.Db, ctx).Delete(&token); result.Error != nil {
 		return result.Error
 	}
 
 	return nil
 }
+
+func (t *TokenRepository) GetTokensOfSubscription(subscriptionId uint, ctx context.Context) ([]models.Token, error) {
+	var tokens []models.Token
+
+	result := connection(t.Db, ctx).Where("subscription_id = ?", subscriptionId).Order("created_at DESC").Find(&tokens)
+	return tokens, result.Error
+}
+
+func (t *TokenRepository) GetLastTokenTime(subscriptionId uint, ctx context.Context) (time.Time, error) {
+	var last sql.NullTime
+
+	result := connection(t.Db, ctx).Model(&models.Token{}).Where("subscription_id = ?", subscriptionId).Select("MAX(created_at)").Scan(&last)
+	return last.Time, result.Error
+}
+
+func (t *TokenRepository) DeleteTokensOfSubscription(subscriptionId uint, ctx context.Context) error {
+	result := connection(t.Db, ctx).Where("subscription_id = ?", subscriptionId).Delete(&models.Token{})
+	return result.Error
+}
@@ -0,0 +1,63 @@
+package persistance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/migrations"
+	"github.com/google/uuid"
+)
+
+// Links emailed before tokens were hashed carry raw tokens, so hash computed
+// by migration should be the one HashToken looks tokens up by.
+func TestHashTokensMigrationKeepsIssuedTokens(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// roll back to schema which stored raw tokens
+	for {
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migration == nil {
+			t.Fatal("hash tokens migration was not applied")
+		}
+		if migration.Version == 2 {
+			break
+		}
+	}
+
+	var subscriptionId uint
+	err = db.Raw("INSERT INTO subscriptions (email, city, frequency, confirmed) VALUES (?, ?, ?, ?) RETURNING id",
+		"user@example.com", "Kyiv", "daily", true).Scan(&subscriptionId).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := uuid.New()
+	err = db.Exec("INSERT INTO tokens (id, expires, subscription_id) VALUES (?, ?, ?)",
+		token, time.Now().Add(time.Hour), subscriptionId).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	repository := NewTokenRepository(db)
+	stored, err := repository.GetToken(token, ctx)
+	if err != nil {
+		t.Fatalf("issued token is not found: %v", err)
+	}
+	if stored.Hash != HashToken(token) || stored.SubscriptionID != subscriptionId {
+		t.Errorf("token %q of subscription %d, want %q of %d", stored.Hash, stored.SubscriptionID, HashToken(token), subscriptionId)
+	}
+}
@@ -0,0 +1,40 @@
+package persistance
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type (
+	UnitOfWork struct {
+		Db *gorm.DB
+	}
+
+	transactionKey struct{}
+)
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{db}
+}
+
+// Execute runs fn inside single database transaction. Repositories called
+// with context passed to fn take part in that transaction.
+func (u *UnitOfWork) Execute(fn func(context.Context) error, ctx context.Context) error {
+	if _, ok := ctx.Value(transactionKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	return u.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, transactionKey{}, tx))
+	})
+}
+
+// connection returns transaction bound to ctx, if there is one, or db otherwise.
+func connection(db *gorm.DB, ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(transactionKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,128 @@
+// Package ratelimit counts requests in fixed time windows.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Limit allows Requests per Window. Zero limit allows everything.
+	Limit struct {
+		Requests int
+		Window   time.Duration
+	}
+
+	// Store counts hits of key in window which is current at the moment of
+	// call, returning count including this hit and end of the window.
+	Store interface {
+		Hit(key string, window time.Duration, ctx context.Context) (int, time.Time, error)
+	}
+
+	// MemoryStore keeps counters of single process.
+	MemoryStore struct {
+		mu       sync.Mutex
+		counters map[string]counter
+		swept    time.Time
+		now      func() time.Time
+	}
+
+	counter struct {
+		count int
+		reset time.Time
+	}
+)
+
+// sweepInterval is how often MemoryStore forgets counters of ended windows.
+const sweepInterval = time.Minute
+
+// ParseLimit reads limit written as `<requests>/<window>`, e.g. `5/1m`.
+// `off` and `0` disable limit.
+func ParseLimit(raw string) (Limit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "off" || raw == "0" {
+		return Limit{}, nil
+	}
+
+	rawRequests, rawWindow, ok := strings.Cut(raw, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit `%s` should look like `5/1m`", raw)
+	}
+
+	requests, err := strconv.Atoi(rawRequests)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("limit `%s` should start with non-negative number of requests", raw)
+	}
+
+	window, err := time.ParseDuration(rawWindow)
+	if err != nil || window <= 0 {
+		return Limit{}, fmt.Errorf("limit `%s` should end with positive duration", raw)
+	}
+
+	return Limit{Requests: requests, Window: window}, nil
+}
+
+// UnmarshalText lets limit be read from configuration in ParseLimit format.
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = limit
+	return nil
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// WindowStart aligns t to start of window, so all processes sharing store
+// agree on windows.
+func WindowStart(t time.Time, window time.Duration) time.Time {
+	return t.Truncate(window)
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]counter), now: time.Now}
+}
+
+func (m *MemoryStore) Hit(key string, window time.Duration, ctx context.Context) (int, time.Time, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.swept) > sweepInterval {
+		for k, c := range m.counters {
+			if !now.Before(c.reset) {
+				delete(m.counters, k)
+			}
+		}
+		m.swept = now
+	}
+
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.reset) {
+		c = counter{reset: WindowStart(now, window).Add(window)}
+	}
+	c.count++
+	m.counters[key] = c
+
+	return c.count, c.reset, nil
+}
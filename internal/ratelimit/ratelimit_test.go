@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is time tests move by hand.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore(start time.Time) (*MemoryStore, *clock) {
+	c := &clock{now: start}
+	store := NewMemoryStore()
+	store.now = c.Now
+	return store, c
+}
+
+func hit(t *testing.T, store Store, key string, window time.Duration) (int, time.Time) {
+	t.Helper()
+
+	count, reset, err := store.Hit(key, window, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count, reset
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		raw   string
+		limit Limit
+		valid bool
+	}{
+		{"5/1m", Limit{Requests: 5, Window: time.Minute}, true},
+		{" 100/1h ", Limit{Requests: 100, Window: time.Hour}, true},
+		{"off", Limit{}, true},
+		{"0", Limit{}, true},
+		{"5", Limit{}, false},
+		{"-1/1m", Limit{}, false},
+		{"5/0s", Limit{}, false},
+		{"5/minute", Limit{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			limit, err := ParseLimit(test.raw)
+			if test.valid && err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("accepted as %v", limit)
+			}
+			if limit != test.limit {
+				t.Errorf("parsed as %v, want %v", limit, test.limit)
+			}
+		})
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	for _, raw := range []string{"5/1m0s", "off"} {
+		limit, err := ParseLimit(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit.String() != raw {
+			t.Errorf("%q is written as %q", raw, limit.String())
+		}
+	}
+}
+
+func TestMemoryStoreCountsHitsInWindow(t *testing.T) {
+	start := time.Date(2025, 5, 1, 12, 0, 10, 0, time.UTC)
+	store, clock := newTestStore(start)
+	window := time.Minute
+	// windows are aligned, so all processes agree on them
+	end := time.Date(2025, 5, 1, 12, 1, 0, 0, time.UTC)
+
+	for want := 1; want <= 3; want++ {
+		count, reset := hit(t, store, "subscribe", window)
+		if count != want || !reset.Equal(end) {
+			t.Fatalf("hit %d counted as %d resetting at %s, want reset at %s", want, count, reset, end)
+		}
+		clock.advance(10 * time.Second)
+	}
+
+	// keys are counted separately
+	if count, _ := hit(t, store, "weather", window); count != 1 {
+		t.Errorf("other key counted as %d", count)
+	}
+
+	// counter starts over right at the end of window
+	clock.now = end
+	count, reset := hit(t, store, "subscribe", window)
+	if count != 1 || !reset.Equal(end.Add(window)) {
+		t.Errorf("hit of next window counted as %d resetting at %s", count, reset)
+	}
+}
+
+func TestMemoryStoreForgetsEndedWindows(t *testing.T) {
+	store, clock := newTestStore(time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	hit(t, store, "short", time.Second)
+	hit(t, store, "long", time.Hour)
+
+	clock.advance(sweepInterval + time.Second)
+	hit(t, store, "other", time.Second)
+
+	if _, ok := store.counters["short"]; ok {
+		t.Error("counter of ended window is kept")
+	}
+	if _, ok := store.counters["long"]; !ok {
+		t.Error("counter of current window is forgotten")
+	}
+}
+
+func TestMemoryStoreCountsConcurrentHits(t *testing.T) {
+	store, _ := newTestStore(time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	const hits = 100
+	var wg sync.WaitGroup
+	for range hits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = store.Hit("key", time.Hour, context.Background())
+		}()
+	}
+	wg.Wait()
+
+	if count, _ := hit(t, store, "key", time.Hour); count != hits+1 {
+		t.Errorf("%d hits counted, want %d", count-1, hits)
+	}
+}
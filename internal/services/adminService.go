@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+)
+
+const (
+	AuditConfirm    = "confirm"
+	AuditDeactivate = "deactivate"
+	AuditDelete     = "delete"
+
+	// DeliveryHistoryLimit is how many latest deliveries are shown in subscription details.
+	DeliveryHistoryLimit = 50
+)
+
+type (
+	// AdminService serves operators, every change it makes is audited.
+	AdminService struct {
+		subscriptionRepository AdminSubscriptionRepository
+		tokenRepository        AdminTokenRepository
+		deliveryRepository     DeliveryRepository
+		auditRepository        AuditRepository
+		unitOfWork             UnitOfWork
+	}
+
+	AdminSubscriptionRepository interface {
+		SearchSubscriptions(filter models.SubscriptionFilter, ctx context.Context) ([]models.Subscription, int64, error)
+		GetSubscriptionById(id uint, ctx context.Context) (models.Subscription, error)
+		Confirm(id uint, ctx context.Context) error
+		Deactivate(id uint, ctx context.Context) error
+		DeleteSubscription(id uint, ctx context.Context) error
+	}
+
+	AdminTokenRepository interface {
+		GetTokensOfSubscription(subscriptionId uint, ctx context.Context) ([]models.Token, error)
+	}
+
+	AuditRepository interface {
+		AddRecord(record models.AuditRecord, ctx context.Context) error
+		GetRecords(offset int, limit int, ctx context.Context) ([]models.AuditRecord, int64, error)
+	}
+)
+
+func NewAdminService(subscriptionRepository AdminSubscriptionRepository, tokenRepository AdminTokenRepository, deliveryRepository DeliveryRepository, auditRepository AuditRepository, unitOfWork UnitOfWork) *AdminService {
+	return &AdminService{subscriptionRepository, tokenRepository, deliveryRepository, auditRepository, unitOfWork}
+}
+
+func (a *AdminService) SearchSubscriptions(filter models.SubscriptionFilter, ctx context.Context) ([]models.Subscription, int64, error) {
+	return a.subscriptionRepository.SearchSubscriptions(filter, ctx)
+}
+
+func (a *AdminService) GetSubscriptionDetails(id uint, ctx context.Context) (models.SubscriptionDetails, error) {
+	var details models.SubscriptionDetails
+	var err error
+
+	details.Subscription, err = a.subscriptionRepository.GetSubscriptionById(id, ctx)
+	if err != nil {
+		return details, err
+	}
+
+	details.Tokens, err = a.tokenRepository.GetTokensOfSubscription(id, ctx)
+	if err != nil {
+		return details, err
+	}
+
+	details.Deliveries, err = a.deliveryRepository.GetDeliveriesOfSubscription(id, DeliveryHistoryLimit, ctx)
+	return details, err
+}
+
+func (a *AdminService) ConfirmSubscription(actor string, id uint, ctx context.Context) (models.Subscription, error) {
+	return a.change(actor, AuditConfirm, id, a.subscriptionRepository.Confirm, ctx)
+}
+
+func (a *AdminService) DeactivateSubscription(actor string, id uint, ctx context.Context) (models.Subscription, error) {
+	return a.change(actor, AuditDeactivate, id, a.subscriptionRepository.Deactivate, ctx)
+}
+
+func (a *AdminService) DeleteSubscription(actor string, id uint, ctx context.Context) error {
+	return a.unitOfWork.Execute(func(ctx context.Context) error {
+		subscription, err := a.subscriptionRepository.GetSubscriptionById(id, ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := a.subscriptionRepository.DeleteSubscription(id, ctx); err != nil {
+			return err
+		}
+
+		return a.audit(actor, AuditDelete, subscription, ctx)
+	}, ctx)
+}
+
+func (a *AdminService) GetAuditRecords(offset int, limit int, ctx context.Context) ([]models.AuditRecord, int64, error) {
+	return a.auditRepository.GetRecords(offset, limit, ctx)
+}
+
+// change applies action to subscription and audits it in one transaction.
+func (a *AdminService) change(actor string, action string, id uint, apply func(uint, context.Context) error, ctx context.Context) (models.Subscription, error) {
+	var subscription models.Subscription
+
+	err := a.unitOfWork.Execute(func(ctx context.Context) error {
+		if err := apply(id, ctx); err != nil {
+			return err
+		}
+
+		var err error
+		subscription, err = a.subscriptionRepository.GetSubscriptionById(id, ctx)
+		if err != nil {
+			return err
+		}
+
+		return a.audit(actor, action, subscription, ctx)
+	}, ctx)
+
+	return subscription, err
+}
+
+func (a *AdminService) audit(actor string, action string, subscription models.Subscription, ctx context.Context) error {
+	return a.auditRepository.AddRecord(models.AuditRecord{
+		Actor:          actor,
+		Action:         action,
+		SubscriptionID: subscription.ID,
+		Details:        fmt.Sprintf("email=%s city=%s frequency=%s", subscription.Email, subscription.City, subscription.Frequency),
+	}, ctx)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+)
+
+const (
+	ApiKeyPrefix = "wm_"
+
+	AuditApiKeyCreate = "apikey-create"
+	AuditApiKeyRevoke = "apikey-revoke"
+)
+
+var (
+	ErrInvalidApiKey = apperrors.New(apperrors.Unauthorized, "API key is invalid or revoked")
+	ErrApiKeyQuota   = apperrors.New(apperrors.Throttled, "daily quota of API key is exhausted")
+)
+
+type (
+	ApiKeyService struct {
+		apiKeyRepository ApiKeyRepository
+		auditRepository  AuditRepository
+		unitOfWork       UnitOfWork
+	}
+
+	ApiKeyRepository interface {
+		AddKey(key models.ApiKey, ctx context.Context) (models.ApiKey, error)
+		GetKeys(ctx context.Context) ([]models.ApiKey, error)
+		GetKeyByHash(hash string, ctx context.Context) (models.ApiKey, error)
+		RevokeKey(id uint, ctx context.Context) error
+		CountUsage(id uint, day time.Time, ctx context.Context) (int, error)
+	}
+)
+
+func NewApiKeyService(apiKeyRepository ApiKeyRepository, auditRepository AuditRepository, unitOfWork UnitOfWork) *ApiKeyService {
+	return &ApiKeyService{apiKeyRepository, auditRepository, unitOfWork}
+}
+
+func hashApiKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateKey issues new key and returns it together with its raw value,
+// which is shown once and can not be recovered.
+func (a *ApiKeyService) CreateKey(actor string, name string, scopes []string, dailyQuota int, ctx context.Context) (models.ApiKey, string, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return models.ApiKey{}, "", apperrors.New(apperrors.Validation, fmt.Sprintf("unknown scope `%s`", scope))
+		}
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return models.ApiKey{}, "", err
+	}
+	raw := ApiKeyPrefix + hex.EncodeToString(secret)
+
+	var key models.ApiKey
+	err := a.unitOfWork.Execute(func(ctx context.Context) error {
+		var err error
+		key, err = a.apiKeyRepository.AddKey(models.ApiKey{
+			Name:       name,
+			Prefix:     raw[:len(ApiKeyPrefix)+8],
+			Hash:       hashApiKey(raw),
+			Scopes:     strings.Join(scopes, ","),
+			DailyQuota: dailyQuota,
+		}, ctx)
+		if err != nil {
+			return err
+		}
+
+		return a.audit(actor, AuditApiKeyCreate, key, ctx)
+	}, ctx)
+	if err != nil {
+		return key, "", err
+	}
+
+	return key, raw, nil
+}
+
+func (a *ApiKeyService) RevokeKey(actor string, id uint, ctx context.Context) error {
+	return a.unitOfWork.Execute(func(ctx context.Context) error {
+		if err := a.apiKeyRepository.RevokeKey(id, ctx); err != nil {
+			return err
+		}
+
+		return a.audit(actor, AuditApiKeyRevoke, models.ApiKey{ID: id}, ctx)
+	}, ctx)
+}
+
+func (a *ApiKeyService) GetKeys(ctx context.Context) ([]models.ApiKey, error) {
+	return a.apiKeyRepository.GetKeys(ctx)
+}
+
+// Authenticate finds active key by its raw value and counts request made
+// with it against its daily quota.
+func (a *ApiKeyService) Authenticate(raw string, ctx context.Context) (models.ApiKey, error) {
+	key, err := a.apiKeyRepository.GetKeyByHash(hashApiKey(raw), ctx)
+	if apperrors.KindOf(err) == apperrors.NotFound || (err == nil && key.RevokedAt != nil) {
+		return key, ErrInvalidApiKey
+	}
+	if err != nil {
+		return key, err
+	}
+
+	used, err := a.apiKeyRepository.CountUsage(key.ID, time.Now().UTC().Truncate(24*time.Hour), ctx)
+	if err != nil {
+		return key, err
+	}
+
+	if key.DailyQuota > 0 && used > key.DailyQuota {
+		return key, ErrApiKeyQuota
+	}
+
+	return key, nil
+}
+
+func (a *ApiKeyService) audit(actor string, action string, key models.ApiKey, ctx context.Context) error {
+	return a.auditRepository.AddRecord(models.AuditRecord{
+		Actor:   actor,
+		Action:  action,
+		Details: fmt.Sprintf("api_key_id=%d name=%s prefix=%s scopes=%s", key.ID, key.Name, key.Prefix, key.Scopes),
+	}, ctx)
+}
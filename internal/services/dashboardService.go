@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+)
+
+const (
+	// DashboardDays is how many latest days of deliveries dashboard shows.
+	DashboardDays = 14
+	// DashboardTopCities is how many cities dashboard ranks.
+	DashboardTopCities = 10
+)
+
+type (
+	DashboardService struct {
+		statisticsRepository StatisticsRepository
+		weatherUsage         WeatherUsageReporter
+	}
+
+	StatisticsRepository interface {
+		CountSubscribers(ctx context.Context) (models.SubscriberCounts, error)
+		GroupSubscriptions(ctx context.Context) ([]models.SubscriptionGroup, error)
+		TopCities(limit int, ctx context.Context) ([]models.CityCount, error)
+		CountDeliveries(since time.Time, ctx context.Context) ([]models.DeliveryCount, error)
+	}
+
+	WeatherUsageReporter interface {
+		Usage() models.WeatherUsage
+	}
+)
+
+func NewDashboardService(statisticsRepository StatisticsRepository, weatherUsage WeatherUsageReporter) *DashboardService {
+	return &DashboardService{statisticsRepository, weatherUsage}
+}
+
+func (d *DashboardService) GetStatistics(ctx context.Context) (models.Statistics, error) {
+	var statistics models.Statistics
+	var err error
+
+	statistics.Subscribers, err = d.statisticsRepository.CountSubscribers(ctx)
+	if err != nil {
+		return statistics, err
+	}
+
+	statistics.Breakdown, err = d.statisticsRepository.GroupSubscriptions(ctx)
+	if err != nil {
+		return statistics, err
+	}
+
+	statistics.TopCities, err = d.statisticsRepository.TopCities(DashboardTopCities, ctx)
+	if err != nil {
+		return statistics, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	statistics.Deliveries, err = d.statisticsRepository.CountDeliveries(today.AddDate(0, 0, 1-DashboardDays), ctx)
+	if err != nil {
+		return statistics, err
+	}
+
+	statistics.WeatherUsage = d.weatherUsage.Usage()
+	return statistics, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+)
+
+type (
+	DeliveryService struct {
+		deliveryRepository DeliveryRepository
+	}
+
+	DeliveryRepository interface {
+		AddDelivery(delivery models.Delivery, ctx context.Context) error
+		GetDeliveriesOfSubscription(subscriptionId uint, limit int, ctx context.Context) ([]models.Delivery, error)
+	}
+)
+
+func NewDeliveryService(deliveryRepository DeliveryRepository) *DeliveryService {
+	return &DeliveryService{deliveryRepository}
+}
+
+// RecordDelivery stores outcome of sending letter of given kind to subscriber.
+func (d *DeliveryService) RecordDelivery(subscriptionId uint, kind string, recipient string, sendErr error, ctx context.Context) error {
+	delivery := models.Delivery{
+		SubscriptionID: subscriptionId,
+		Kind:           kind,
+		Recipient:      recipient,
+		Status:         models.DeliverySent,
+	}
+
+	if sendErr != nil {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = sendErr.Error()
+	}
+
+	return d.deliveryRepository.AddDelivery(delivery, ctx)
+}
+
+func (d *DeliveryService) GetDeliveriesOfSubscription(subscriptionId uint, limit int, ctx context.Context) ([]models.Delivery, error) {
+	return d.deliveryRepository.GetDeliveriesOfSubscription(subscriptionId, limit, ctx)
+}
@@ -1,32 +1,77 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	netmail "net/mail"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Rabiann/weather-mailer/internal/config"
+	"github.com/Rabiann/weather-mailer/internal/metrics"
 	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/Rabiann/weather-mailer/internal/tracing"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// mailTransport labels metrics of letters sent through SendGrid.
+	mailTransport = "sendgrid"
+	// sendgridScopesUrl lists scopes of API key, which is the cheapest
+	// request telling that SendGrid is reachable and key is accepted.
+	sendgridScopesUrl = "https://api.sendgrid.com/v3/scopes"
+	// transportCheckInterval is how long result of transport check is
+	// reused, so that probes do not eat SendGrid rate limit.
+	transportCheckInterval = time.Minute
+
+	confirmationTemplatePath = "./templates/confirmationMail.tmpl"
+	weatherTemplatePath      = "./templates/weatherMail.tmpl"
 )
 
 type (
 	MailingService struct {
-		Client               *sendgrid.Client
-		ConfirmationTemplate *ConfirmationTemplate
-		WeatherTemplate      *WeatherTemplate
-		Config               *config.Configuration
+		Client *sendgrid.Client
+		Config *config.Configuration
+		Logger *slog.Logger
+
+		// letters is replaced as a whole by Reload, every letter reads it
+		// once, so that it is built and sent with one snapshot
+		letters atomic.Pointer[letterSettings]
+
+		mu                 sync.Mutex
+		transportCheckedAt time.Time
+		transportErr       error
+	}
+
+	// letterSettings are settings of letters which can be reloaded
+	// without restart.
+	letterSettings struct {
+		confirmationTemplate *ConfirmationTemplate
+		weatherTemplate      *WeatherTemplate
+		timeout              time.Duration
 	}
 
 	MailingServer interface {
-		SendConfirmationLetter(string, string) error
+		SendConfirmationLetter(string, string, context.Context) error
 		sendLetter(MailOptions, context.Context) error
-		SendWeatherReport(*models.Subscriber, *models.Weather, string) error
+		SendWeatherReport(*models.Subscriber, *models.Weather, string, context.Context) error
+		RenderWeatherReport(*models.Subscriber, *models.Weather, string, io.Writer) error
 	}
 
 	MailOptions struct {
+		// kind is type of letter, one of delivery kinds
+		kind    string
 		from    mail.Email
 		to      mail.Email
 		subject string
@@ -34,49 +79,132 @@ type (
 	}
 )
 
-func NewMailingService(config *config.Configuration) (*MailingService, error) {
+func NewMailingService(config *config.Configuration, logger *slog.Logger) (*MailingService, error) {
 	var ms MailingService
 	client := sendgrid.NewSendClient(config.SendgridApiKey)
 	ms.Client = client
+	ms.Config = config
+	ms.Logger = logger
 
-	confirmationTemplate, err := NewConfirmationTemplate("./templates/confirmationMail.tmpl")
-	if err != nil {
+	if err := ms.Reload(config.MailTimeout); err != nil {
 		return nil, err
 	}
 
-	weatherTemplate, err := NewWeatherTemplate("./templates/weatherMail.tmpl")
+	return &ms, nil
+}
+
+// Reload reads letter templates again and replaces them together with
+// timeout of sending. Nothing is replaced when some template is invalid.
+func (s *MailingService) Reload(timeout time.Duration) error {
+	confirmationTemplate, err := NewConfirmationTemplate(confirmationTemplatePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	ms.ConfirmationTemplate = confirmationTemplate
-	ms.WeatherTemplate = weatherTemplate
-	ms.Config = config
-	return &ms, nil
+	weatherTemplate, err := NewWeatherTemplate(weatherTemplatePath)
+	if err != nil {
+		return err
+	}
+
+	s.letters.Store(&letterSettings{
+		confirmationTemplate: confirmationTemplate,
+		weatherTemplate:      weatherTemplate,
+		timeout:              timeout,
+	})
+	return nil
+}
+
+// CheckTemplates tells whether letter templates are loaded.
+func (s *MailingService) CheckTemplates(context.Context) (map[string]any, error) {
+	if s.letters.Load() == nil {
+		return nil, errors.New("letter templates are not loaded")
+	}
+
+	return nil, nil
+}
+
+// CheckTransport tells whether SendGrid is reachable and accepts API key.
+func (s *MailingService) CheckTransport(ctx context.Context) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.transportCheckedAt) >= transportCheckInterval {
+		s.transportErr = s.checkTransport(ctx)
+		s.transportCheckedAt = time.Now()
+	}
+
+	return map[string]any{
+		"transport": mailTransport,
+		"checkedAt": s.transportCheckedAt.UTC(),
+	}, s.transportErr
+}
+
+func (s *MailingService) checkTransport(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, sendgridScopesUrl, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+s.Config.SendgridApiKey)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("mail transport responded with status %d", response.StatusCode)
+	}
+
+	return nil
 }
 
-func (s *MailingService) sendLetter(options MailOptions, ctx context.Context) error {
+func (s *MailingService) sendLetter(options MailOptions, ctx context.Context) (err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "MailingService.sendLetter",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("mail.type", options.kind), attribute.String("mail.transport", mailTransport)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
 	message := mail.NewSingleEmail(&options.from, options.subject, &options.to, "", options.content)
-	_, err := s.Client.SendWithContext(ctx, message)
-	return err
+	response, err := s.Client.SendWithContext(ctx, message)
+	if err == nil && response.StatusCode >= 300 {
+		err = fmt.Errorf("mail transport responded with status %d", response.StatusCode)
+	}
+	metrics.Mails.WithLabelValues(options.kind, mailTransport, metrics.Outcome(err)).Inc()
+
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "letter not sent", slog.String("subject", options.subject), slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+		return err
+	}
+
+	s.Logger.DebugContext(ctx, "letter sent", slog.String("subject", options.subject), slog.Duration("duration", time.Since(start)))
+	return nil
 }
 
-func (s *MailingService) SendConfirmationLetter(recipient string, confirmationUrl string) error {
+func (s *MailingService) SendConfirmationLetter(recipient string, confirmationUrl string, ctx context.Context) error {
 	from := mail.Email{
 		Name:    "Confirmator",
-		Address: os.Getenv("SENDER_MAIL"),
+		Address: s.Config.SenderMail,
 	}
 	to := mail.Email{
 		Name:    recipient,
 		Address: recipient,
 	}
 
+	letters := s.letters.Load()
 	subject := "Confirm Weather Subscription"
-	body := s.ConfirmationTemplate.buildConfirmationLetter(confirmationUrl)
+	body, err := letters.confirmationTemplate.buildConfirmationLetter(ConfirmationLetter{ConfirmationUrl: confirmationUrl})
+	if err != nil {
+		return err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(s.Config.MailTimeout))
+	// letter is sent even when caller gives up, so that it is not sent twice
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), letters.timeout)
 	defer cancel()
 	options := MailOptions{
+		kind:    models.DeliveryConfirmation,
 		from:    from,
 		to:      to,
 		subject: subject,
@@ -86,10 +214,34 @@ func (s *MailingService) SendConfirmationLetter(recipient string, confirmationUr
 	return s.sendLetter(options, ctx)
 }
 
-func (s *MailingService) SendWeatherReport(subscriber *models.Subscriber, weather *models.Weather, unsubscribingUrl string) error {
+func (s *MailingService) SendWeatherReport(subscriber *models.Subscriber, weather *models.Weather, unsubscribingUrl string, ctx context.Context) error {
+	letters := s.letters.Load()
+	options, err := s.weatherReport(subscriber, weather, unsubscribingUrl, letters)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), letters.timeout)
+	defer cancel()
+
+	return s.sendLetter(options, ctx)
+}
+
+// RenderWeatherReport writes weather report to w as `.eml` message instead
+// of sending it.
+func (s *MailingService) RenderWeatherReport(subscriber *models.Subscriber, weather *models.Weather, unsubscribingUrl string, w io.Writer) error {
+	options, err := s.weatherReport(subscriber, weather, unsubscribingUrl, s.letters.Load())
+	if err != nil {
+		return err
+	}
+
+	return writeMessage(options, w)
+}
+
+func (s *MailingService) weatherReport(subscriber *models.Subscriber, weather *models.Weather, unsubscribingUrl string, letters *letterSettings) (MailOptions, error) {
 	from := mail.Email{
 		Name:    "Reporter",
-		Address: os.Getenv("SENDER_MAIL"),
+		Address: s.Config.SenderMail,
 	}
 	to := mail.Email{
 		Name:    subscriber.Recipient,
@@ -97,16 +249,50 @@ func (s *MailingService) SendWeatherReport(subscriber *models.Subscriber, weathe
 	}
 
 	subject := fmt.Sprintf("%s report for %s", subscriber.Period, subscriber.City)
-	body := s.WeatherTemplate.buildWeatherLetter(subscriber.City, fmt.Sprintf("%.1f", weather.Temperature), fmt.Sprintf("%.1f", weather.Humidity), weather.Description, unsubscribingUrl)
+	body, err := letters.weatherTemplate.buildWeatherLetter(WeatherLetter{
+		City:           subscriber.City,
+		Period:         subscriber.Period,
+		Temperature:    weather.Temperature,
+		Humidity:       weather.Humidity,
+		Description:    weather.Description,
+		UnsubscribeUrl: unsubscribingUrl,
+	})
+	if err != nil {
+		return MailOptions{}, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
-	options := MailOptions{
+	return MailOptions{
+		kind:    models.DeliveryWeather,
 		from:    from,
 		to:      to,
 		subject: subject,
 		content: body,
+	}, nil
+}
+
+// writeMessage writes letter as MIME message, the way mail clients open it.
+func writeMessage(options MailOptions, w io.Writer) error {
+	from := netmail.Address{Name: options.from.Name, Address: options.from.Address}
+	to := netmail.Address{Name: options.to.Name, Address: options.to.Address}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from.String())
+	fmt.Fprintf(&message, "To: %s\r\n", to.String())
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", options.subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&message)
+	if _, err := body.Write([]byte(options.content)); err != nil {
+		return err
+	}
+	if err := body.Close(); err != nil {
+		return err
 	}
+	message.WriteString("\r\n")
 
-	return s.sendLetter(options, ctx)
+	_, err := message.WriteTo(w)
+	return err
 }
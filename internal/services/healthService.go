@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+)
+
+// HealthCheckTimeout bounds every check, so that hung dependency fails its
+// check instead of the probe.
+const HealthCheckTimeout = 2 * time.Second
+
+type (
+	HealthService struct {
+		checks []HealthCheck
+	}
+
+	// HealthCheck is named check of one component. Readiness fails when
+	// required component fails and is degraded when other one does.
+	HealthCheck struct {
+		Name     string
+		Required bool
+		Checker  HealthChecker
+	}
+
+	// HealthChecker reports whether component works, with optional
+	// details shown to operator.
+	HealthChecker interface {
+		CheckHealth(context.Context) (map[string]any, error)
+	}
+
+	// HealthCheckFunc lets plain function be HealthChecker.
+	HealthCheckFunc func(context.Context) (map[string]any, error)
+)
+
+func (f HealthCheckFunc) CheckHealth(ctx context.Context) (map[string]any, error) {
+	return f(ctx)
+}
+
+func NewHealthService(checks ...HealthCheck) *HealthService {
+	return &HealthService{checks}
+}
+
+// Check runs all checks concurrently and combines their results.
+func (h *HealthService) Check(ctx context.Context) models.Health {
+	health := models.Health{
+		Status:     models.HealthOk,
+		CheckedAt:  time.Now().UTC(),
+		Components: make(map[string]models.ComponentHealth, len(h.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			component := runCheck(check, ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			health.Components[check.Name] = component
+			switch {
+			case component.Status == models.HealthOk:
+			case check.Required:
+				health.Status = models.HealthFail
+			case health.Status == models.HealthOk:
+				health.Status = models.HealthDegraded
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return health
+}
+
+func runCheck(check HealthCheck, ctx context.Context) models.ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.Checker.CheckHealth(ctx)
+	component := models.ComponentHealth{
+		Status:   models.HealthOk,
+		Required: check.Required,
+		Duration: time.Since(start).Round(time.Microsecond).String(),
+		Details:  details,
+	}
+	if err != nil {
+		component.Status = models.HealthFail
+		component.Error = err.Error()
+	}
+
+	return component
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Rabiann/weather-mailer/internal/models"
+)
+
+// stubChecker reports err, with details naming it.
+func stubChecker(name string, err error) HealthCheckFunc {
+	return func(context.Context) (map[string]any, error) {
+		return map[string]any{"name": name}, err
+	}
+}
+
+func TestHealthCheckCombinesComponents(t *testing.T) {
+	down := errors.New("down")
+
+	tests := []struct {
+		name   string
+		checks []HealthCheck
+		status string
+	}{
+		{name: "no checks", status: models.HealthOk},
+		{
+			name: "everything works",
+			checks: []HealthCheck{
+				{Name: "database", Required: true, Checker: stubChecker("database", nil)},
+				{Name: "weather", Checker: stubChecker("weather", nil)},
+			},
+			status: models.HealthOk,
+		},
+		{
+			name: "optional component fails",
+			checks: []HealthCheck{
+				{Name: "database", Required: true, Checker: stubChecker("database", nil)},
+				{Name: "weather", Checker: stubChecker("weather", down)},
+			},
+			status: models.HealthDegraded,
+		},
+		{
+			name: "required component fails",
+			checks: []HealthCheck{
+				{Name: "database", Required: true, Checker: stubChecker("database", down)},
+				{Name: "weather", Checker: stubChecker("weather", nil)},
+			},
+			status: models.HealthFail,
+		},
+		// failure is not hidden by degradation, whichever finishes first
+		{
+			name: "required and optional components fail",
+			checks: []HealthCheck{
+				{Name: "weather", Checker: stubChecker("weather", down)},
+				{Name: "database", Required: true, Checker: stubChecker("database", down)},
+				{Name: "mail", Checker: stubChecker("mail", down)},
+			},
+			status: models.HealthFail,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			health := NewHealthService(test.checks...).Check(context.Background())
+			if health.Status != test.status {
+				t.Errorf("status is %s, want %s", health.Status, test.status)
+			}
+			if len(health.Components) != len(test.checks) {
+				t.Fatalf("%d components reported, want %d", len(health.Components), len(test.checks))
+			}
+
+			for _, check := range test.checks {
+				component := health.Components[check.Name]
+				_, err := check.Checker.CheckHealth(context.Background())
+
+				want := models.HealthOk
+				if err != nil {
+					want = models.HealthFail
+				}
+				if component.Status != want || component.Required != check.Required {
+					t.Errorf("%s is %+v, want %s", check.Name, component, want)
+				}
+				if err != nil && component.Error != err.Error() {
+					t.Errorf("%s error is %q", check.Name, component.Error)
+				}
+				if component.Details["name"] != check.Name {
+					t.Errorf("%s details are %v", check.Name, component.Details)
+				}
+			}
+		})
+	}
+}
+
+// Every check is bounded, so hung dependency fails only its own check.
+func TestHealthCheckHasDeadline(t *testing.T) {
+	check := HealthCheck{Name: "database", Required: true, Checker: HealthCheckFunc(func(ctx context.Context) (map[string]any, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("check has no deadline")
+		}
+		return nil, nil
+	})}
+
+	health := NewHealthService(check).Check(context.Background())
+	if health.Status != models.HealthOk {
+		t.Errorf("status is %s: %+v", health.Status, health.Components)
+	}
+}
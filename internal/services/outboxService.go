@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	ConfirmationMessage = "confirmation"
+
+	// OutboxMaxAttempts limits how many times relay retries single message.
+	OutboxMaxAttempts = 5
+	// OutboxGracePeriod gives immediate dispatch after commit time to finish
+	// before relay picks message up.
+	OutboxGracePeriod = time.Minute
+	// OutboxBatchSize limits how many messages relay picks up at once.
+	OutboxBatchSize = 100
+	// OutboxClaimTimeout is how long claimed message is left to its sender.
+	// Messages whose sender died before recording outcome are retried after.
+	OutboxClaimTimeout = 5 * time.Minute
+)
+
+type (
+	// OutboxService delivers letters enqueued in transactions. Messages keep
+	// only subscription they are for, links with tokens are minted right
+	// before letter is sent, so raw tokens never reach database.
+	OutboxService struct {
+		outboxRepository OutboxRepository
+		tokenIssuer      TokenIssuer
+		emailService     EmailServer
+		deliveryRecorder DeliveryRecorder
+		unitOfWork       UnitOfWork
+		baseUrl          string
+		logger           *slog.Logger
+	}
+
+	OutboxRepository interface {
+		AddMessage(message models.OutboxMessage, ctx context.Context) (uint, error)
+		DeletePending(subscriptionId uint, kind string, ctx context.Context) error
+		ClaimMessage(id uint, until time.Time, ctx context.Context) (models.OutboxMessage, error)
+		GetPendingMessages(createdBefore time.Time, maxAttempts int, limit int, ctx context.Context) ([]models.OutboxMessage, error)
+		MarkSent(id uint, ctx context.Context) error
+		MarkFailed(id uint, reason string, ctx context.Context) error
+	}
+
+	TokenIssuer interface {
+		CreateToken(uint, context.Context) (uuid.UUID, error)
+	}
+
+	EmailServer interface {
+		SendConfirmationLetter(recipient string, confirmationUrl string, ctx context.Context) error
+	}
+
+	DeliveryRecorder interface {
+		RecordDelivery(subscriptionId uint, kind string, recipient string, sendErr error, ctx context.Context) error
+	}
+)
+
+func NewOutboxService(outboxRepository OutboxRepository, tokenIssuer TokenIssuer, emailService EmailServer, deliveryRecorder DeliveryRecorder, unitOfWork UnitOfWork, baseUrl string, logger *slog.Logger) *OutboxService {
+	return &OutboxService{outboxRepository, tokenIssuer, emailService, deliveryRecorder, unitOfWork, baseUrl, logger}
+}
+
+// EnqueueConfirmation records confirmation letter to be sent. Confirmations
+// still pending for subscription are superseded by it, so that subscriber
+// does not get one letter per request. It should be called in the same
+// transaction as changes letter confirms.
+func (o *OutboxService) EnqueueConfirmation(subscriptionId uint, recipient string, ctx context.Context) (uint, error) {
+	if err := o.outboxRepository.DeletePending(subscriptionId, ConfirmationMessage, ctx); err != nil {
+		return 0, err
+	}
+
+	message := models.OutboxMessage{
+		SubscriptionID: subscriptionId,
+		Kind:           ConfirmationMessage,
+		Recipient:      recipient,
+	}
+
+	return o.outboxRepository.AddMessage(message, ctx)
+}
+
+// Dispatch sends message right after it was committed. Messages already
+// sent or being sent by relay are left alone.
+func (o *OutboxService) Dispatch(id uint, ctx context.Context) error {
+	return o.deliver(id, ctx)
+}
+
+// DispatchPending retries messages which were not delivered right after
+// commit. Every message is claimed, sent and recorded on its own, so failure
+// of one does not make others sent again.
+func (o *OutboxService) DispatchPending(ctx context.Context) error {
+	messages, err := o.outboxRepository.GetPendingMessages(time.Now().Add(-OutboxGracePeriod), OutboxMaxAttempts, OutboxBatchSize, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := o.deliver(message.ID, ctx); err != nil {
+			o.logger.WarnContext(ctx, "outbox message not delivered", slog.Uint64("message_id", uint64(message.ID)), slog.Uint64("subscription_id", uint64(message.SubscriptionID)), slog.Any("error", err))
+		}
+	}
+
+	return nil
+}
+
+// RunRelay periodically retries messages which were not delivered right after commit.
+func (o *OutboxService) RunRelay(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := o.DispatchPending(context.Background()); err != nil {
+			o.logger.Error("outbox relay failed", slog.Any("error", err))
+		}
+	}
+}
+
+// deliver claims message together with token for its link, sends letter
+// outside of any transaction and records outcome in a transaction of its
+// own. Token is committed before letter leaves, so link of sent letter
+// always works.
+func (o *OutboxService) deliver(id uint, ctx context.Context) error {
+	var message models.OutboxMessage
+	var url string
+	var linkErr error
+
+	err := o.unitOfWork.Execute(func(ctx context.Context) error {
+		var err error
+		message, err = o.outboxRepository.ClaimMessage(id, time.Now().Add(OutboxClaimTimeout), ctx)
+		if err != nil {
+			return err
+		}
+
+		url, linkErr = o.link(message, ctx)
+		return linkErr
+	}, ctx)
+	// failed link is counted as failed attempt, other errors leave message
+	// to relay
+	if err != nil && linkErr == nil {
+		if apperrors.KindOf(err) == apperrors.NotFound {
+			return nil
+		}
+		return err
+	}
+
+	sendErr := linkErr
+	if sendErr == nil {
+		sendErr = o.send(message, url, ctx)
+	}
+
+	err = o.unitOfWork.Execute(func(ctx context.Context) error {
+		return o.record(message, linkErr == nil, sendErr, ctx)
+	}, ctx)
+	if err != nil {
+		// message stays claimed, it is retried once claim is over
+		o.logger.ErrorContext(ctx, "outcome of outbox message not recorded", slog.Uint64("message_id", uint64(message.ID)), slog.Uint64("subscription_id", uint64(message.SubscriptionID)), slog.Any("error", err))
+	}
+
+	return sendErr
+}
+
+// link mints token for message and builds link it carries.
+func (o *OutboxService) link(message models.OutboxMessage, ctx context.Context) (string, error) {
+	// messages enqueued with links in payload have no subscription to mint token for
+	if message.SubscriptionID == 0 {
+		return "", fmt.Errorf("outbox message has no subscription")
+	}
+
+	switch message.Kind {
+	case ConfirmationMessage:
+		token, err := o.tokenIssuer.CreateToken(message.SubscriptionID, ctx)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%s/api/confirm/%s", o.baseUrl, token), nil
+	default:
+		return "", fmt.Errorf("unknown outbox message kind `%s`", message.Kind)
+	}
+}
+
+// send emails letter of message.
+func (o *OutboxService) send(message models.OutboxMessage, url string, ctx context.Context) error {
+	return o.emailService.SendConfirmationLetter(message.Recipient, url, ctx)
+}
+
+// record marks message sent, or counts failed attempt. Deliveries are
+// recorded only for letters handed to email provider.
+func (o *OutboxService) record(message models.OutboxMessage, attempted bool, sendErr error, ctx context.Context) error {
+	if attempted {
+		if err := o.deliveryRecorder.RecordDelivery(message.SubscriptionID, models.DeliveryConfirmation, message.Recipient, sendErr, ctx); err != nil {
+			return err
+		}
+	}
+
+	if sendErr != nil {
+		return o.outboxRepository.MarkFailed(message.ID, sendErr.Error(), ctx)
+	}
+
+	return o.outboxRepository.MarkSent(message.ID, ctx)
+}
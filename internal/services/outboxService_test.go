@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/google/uuid"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type (
+	// memoryOutbox keeps messages in map, marking messages of failMarks
+	// fails.
+	memoryOutbox struct {
+		messages  map[uint]*models.OutboxMessage
+		failMarks map[uint]bool
+		lastId    uint
+	}
+
+	stubTokenIssuer struct {
+		issued []uuid.UUID
+	}
+
+	// recordingMailer records confirmation links it was asked to send and
+	// fails with err. It also counts letters sent inside transaction.
+	recordingMailer struct {
+		links         []string
+		err           error
+		unitOfWork    *trackingUnitOfWork
+		inTransaction int
+	}
+
+	noDeliveries struct{}
+
+	// directUnitOfWork runs functions without transaction.
+	directUnitOfWork struct{}
+
+	// trackingUnitOfWork runs functions without transaction, but knows
+	// whether one would be open.
+	trackingUnitOfWork struct {
+		active bool
+	}
+)
+
+func (m *memoryOutbox) AddMessage(message models.OutboxMessage, _ context.Context) (uint, error) {
+	m.lastId++
+	message.ID = m.lastId
+	message.CreatedAt = time.Now()
+	m.messages[message.ID] = &message
+	return message.ID, nil
+}
+
+func (m *memoryOutbox) DeletePending(subscriptionId uint, kind string, _ context.Context) error {
+	for id, message := range m.messages {
+		if message.SubscriptionID == subscriptionId && message.Kind == kind && message.SentAt == nil {
+			delete(m.messages, id)
+		}
+	}
+	return nil
+}
+
+func (m *memoryOutbox) ClaimMessage(id uint, until time.Time, _ context.Context) (models.OutboxMessage, error) {
+	message, ok := m.messages[id]
+	if !ok || message.SentAt != nil || (message.ClaimedUntil != nil && message.ClaimedUntil.After(time.Now())) {
+		return models.OutboxMessage{}, apperrors.New(apperrors.NotFound, "outbox message not found")
+	}
+	message.ClaimedUntil = &until
+	return *message, nil
+}
+
+func (m *memoryOutbox) GetPendingMessages(createdBefore time.Time, maxAttempts int, limit int, _ context.Context) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	for _, id := range slices.Sorted(maps.Keys(m.messages)) {
+		message := m.messages[id]
+		if len(messages) == limit {
+			break
+		}
+		claimed := message.ClaimedUntil != nil && message.ClaimedUntil.After(time.Now())
+		if message.SentAt == nil && !claimed && message.Attempts < maxAttempts && message.CreatedAt.Before(createdBefore) {
+			messages = append(messages, *message)
+		}
+	}
+	return messages, nil
+}
+
+func (m *memoryOutbox) MarkSent(id uint, _ context.Context) error {
+	if m.failMarks[id] {
+		return errors.New("database is down")
+	}
+	now := time.Now()
+	m.messages[id].SentAt = &now
+	m.messages[id].ClaimedUntil = nil
+	return nil
+}
+
+func (m *memoryOutbox) MarkFailed(id uint, reason string, _ context.Context) error {
+	if m.failMarks[id] {
+		return errors.New("database is down")
+	}
+	m.messages[id].Attempts++
+	m.messages[id].LastError = reason
+	m.messages[id].ClaimedUntil = nil
+	return nil
+}
+
+func (s *stubTokenIssuer) CreateToken(uint, context.Context) (uuid.UUID, error) {
+	token := uuid.New()
+	s.issued = append(s.issued, token)
+	return token, nil
+}
+
+func (r *recordingMailer) SendConfirmationLetter(recipient string, confirmationUrl string, _ context.Context) error {
+	r.links = append(r.links, confirmationUrl)
+	if r.unitOfWork != nil && r.unitOfWork.active {
+		r.inTransaction++
+	}
+	return r.err
+}
+
+func (noDeliveries) RecordDelivery(uint, string, string, error, context.Context) error { return nil }
+
+func (directUnitOfWork) Execute(fn func(context.Context) error, ctx context.Context) error {
+	return fn(ctx)
+}
+
+func (u *trackingUnitOfWork) Execute(fn func(context.Context) error, ctx context.Context) error {
+	u.active = true
+	defer func() { u.active = false }()
+	return fn(ctx)
+}
+
+func newTestOutbox(mailer *recordingMailer) (*OutboxService, *memoryOutbox, *stubTokenIssuer) {
+	outbox := &memoryOutbox{messages: map[uint]*models.OutboxMessage{}, failMarks: map[uint]bool{}}
+	tokens := &stubTokenIssuer{}
+	mailer.unitOfWork = &trackingUnitOfWork{}
+	service := NewOutboxService(outbox, tokens, mailer, noDeliveries{}, mailer.unitOfWork, "http://localhost:8000", testLogger)
+	return service, outbox, tokens
+}
+
+func TestDispatchMintsTokenWhenSending(t *testing.T) {
+	mailer := &recordingMailer{}
+	service, outbox, tokens := newTestOutbox(mailer)
+	ctx := context.Background()
+
+	id, err := service.EnqueueConfirmation(7, "user@example.com", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens.issued) != 0 {
+		t.Fatal("token was minted before dispatch")
+	}
+
+	if err := service.Dispatch(id, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens.issued) != 1 || len(mailer.links) != 1 {
+		t.Fatalf("%d tokens minted and %d letters sent, want 1 and 1", len(tokens.issued), len(mailer.links))
+	}
+	if want := "http://localhost:8000/api/confirm/" + tokens.issued[0].String(); mailer.links[0] != want {
+		t.Errorf("letter links to %s, want %s", mailer.links[0], want)
+	}
+	if outbox.messages[id].SentAt == nil {
+		t.Error("message is not marked sent")
+	}
+	if mailer.inTransaction != 0 {
+		t.Error("letter was sent inside transaction")
+	}
+
+	// sent message is not sent again
+	if err := service.Dispatch(id, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.links) != 1 {
+		t.Error("sent message was dispatched again")
+	}
+}
+
+func TestFailedDispatchIsRetriedByRelay(t *testing.T) {
+	mailer := &recordingMailer{err: errors.New("provider is down")}
+	service, outbox, tokens := newTestOutbox(mailer)
+	ctx := context.Background()
+
+	id, err := service.EnqueueConfirmation(7, "user@example.com", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Dispatch(id, ctx); err == nil {
+		t.Fatal("failed letter was reported sent")
+	}
+	if message := outbox.messages[id]; message.Attempts != 1 || !strings.Contains(message.LastError, "provider is down") {
+		t.Fatalf("failure is not recorded: %+v", message)
+	}
+
+	// relay picks message up once grace period is over
+	outbox.messages[id].CreatedAt = time.Now().Add(-OutboxGracePeriod - time.Second)
+	mailer.err = nil
+	if err := service.DispatchPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if outbox.messages[id].SentAt == nil {
+		t.Fatal("relay did not send message")
+	}
+	// every attempt carries its own token, none of them is stored
+	if len(tokens.issued) != 2 || strings.Contains(mailer.links[1], tokens.issued[0].String()) {
+		t.Errorf("retry did not mint new token: %v", mailer.links)
+	}
+}
+
+func TestMessageWithoutSubscriptionIsNotSent(t *testing.T) {
+	mailer := &recordingMailer{}
+	service, outbox, _ := newTestOutbox(mailer)
+	ctx := context.Background()
+
+	id, err := outbox.AddMessage(models.OutboxMessage{Kind: ConfirmationMessage, Recipient: "user@example.com"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Dispatch(id, ctx); err == nil {
+		t.Fatal("message without subscription was sent")
+	}
+	if len(mailer.links) != 0 || outbox.messages[id].Attempts != 1 {
+		t.Errorf("%d letters sent, %d attempts recorded", len(mailer.links), outbox.messages[id].Attempts)
+	}
+}
+
+func TestClaimedMessageIsNotSentAgain(t *testing.T) {
+	mailer := &recordingMailer{}
+	service, outbox, _ := newTestOutbox(mailer)
+	ctx := context.Background()
+
+	id, err := service.EnqueueConfirmation(7, "user@example.com", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// someone else is sending it
+	claimedUntil := time.Now().Add(OutboxClaimTimeout)
+	outbox.messages[id].ClaimedUntil = &claimedUntil
+	outbox.messages[id].CreatedAt = time.Now().Add(-OutboxGracePeriod - time.Second)
+
+	if err := service.Dispatch(id, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DispatchPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.links) != 0 {
+		t.Fatalf("claimed message was sent %d times", len(mailer.links))
+	}
+
+	// claim of sender, which died before recording outcome, runs out
+	claimedUntil = time.Now().Add(-time.Second)
+	if err := service.DispatchPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.links) != 1 || outbox.messages[id].SentAt == nil {
+		t.Errorf("message of expired claim was not sent")
+	}
+}
+
+// Every message is recorded on its own, so failing to mark one of them does
+// not make the others be sent again.
+func TestRelayRecordsMessagesSeparately(t *testing.T) {
+	mailer := &recordingMailer{}
+	service, outbox, _ := newTestOutbox(mailer)
+	ctx := context.Background()
+
+	var ids []uint
+	for subscriptionId := range uint(3) {
+		id, err := service.EnqueueConfirmation(subscriptionId+1, "user@example.com", ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outbox.messages[id].CreatedAt = time.Now().Add(-OutboxGracePeriod - time.Second)
+		ids = append(ids, id)
+	}
+	outbox.failMarks[ids[1]] = true
+
+	if err := service.DispatchPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mailer.links) != 3 || mailer.inTransaction != 0 {
+		t.Fatalf("%d letters sent, %d of them inside transaction", len(mailer.links), mailer.inTransaction)
+	}
+	if outbox.messages[ids[0]].SentAt == nil || outbox.messages[ids[2]].SentAt == nil {
+		t.Error("messages recorded fine are not marked sent")
+	}
+	// message left unmarked stays claimed, so relay does not send it right away
+	if outbox.messages[ids[1]].ClaimedUntil == nil {
+		t.Error("unrecorded message is not claimed anymore")
+	}
+	if err := service.DispatchPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.links) != 3 {
+		t.Errorf("%d letters sent after second run, want 3", len(mailer.links))
+	}
+}
+
+func TestEnqueueSupersedesPendingConfirmation(t *testing.T) {
+	mailer := &recordingMailer{}
+	service, outbox, _ := newTestOutbox(mailer)
+	ctx := context.Background()
+
+	first, err := service.EnqueueConfirmation(7, "user@example.com", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.EnqueueConfirmation(8, "other@example.com", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.EnqueueConfirmation(7, "user@example.com", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := outbox.messages[first]; ok {
+		t.Error("pending confirmation was not superseded")
+	}
+	if _, ok := outbox.messages[other]; !ok {
+		t.Error("confirmation of other subscription was superseded")
+	}
+
+	for _, message := range outbox.messages {
+		message.CreatedAt = time.Now().Add(-OutboxGracePeriod - time.Second)
+	}
+	if err := service.Dispatch(first, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DispatchPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.links) != 2 || outbox.messages[second].SentAt == nil {
+		t.Errorf("%d letters sent, want one per subscription", len(mailer.links))
+	}
+
+	// letters already sent are history, they are kept
+	if _, err := service.EnqueueConfirmation(7, "user@example.com", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := outbox.messages[second]; !ok {
+		t.Error("sent confirmation was deleted")
+	}
+}
@@ -2,62 +2,205 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
 	"github.com/Rabiann/weather-mailer/internal/models"
 	"github.com/google/uuid"
 )
 
+// ConfirmationCooldown is minimal interval between two confirmation letters
+// sent to the same unconfirmed address.
+const ConfirmationCooldown = time.Minute * 5
+
+var (
+	ErrAlreadySubscribed    = apperrors.New(apperrors.Conflict, "email already subscribed")
+	ErrAlreadyConfirmed     = apperrors.New(apperrors.Conflict, "subscription already confirmed")
+	ErrConfirmationCooldown = apperrors.New(apperrors.Throttled, "confirmation letter was sent recently")
+	ErrForeignToken         = apperrors.New(apperrors.Unauthorized, "token does not grant access to subscription")
+	ErrExpiredToken         = apperrors.New(apperrors.Expired, "token already expired")
+)
+
 type (
 	SubscriptionControlService struct {
 		subscriptionDataService SubscriptionDataServer
 		tokenService            TokenServer
-		emailService            EmailServer
-		baseUrl                 string
+		outboxService           OutboxServer
+		cityVerifier            CityVerifier
+		unitOfWork              UnitOfWork
+		logger                  *slog.Logger
 	}
 
 	SubscriptionDataServer interface {
+		GetSubscriptionById(uint, context.Context) (models.Subscription, error)
+		GetSubscriptionByEmail(string, context.Context) (*models.Subscription, error)
 		AddSubscription(models.Subscription, context.Context) (uint, error)
 		ActivateSubscription(uint, context.Context) (string, error)
+		UpdateSubscription(uint, models.Subscription, context.Context) error
 		DeleteSubscription(uint, context.Context) error
 	}
 
 	TokenServer interface {
+		GetToken(uuid.UUID, context.Context) (models.Token, error)
 		CreateToken(uint, context.Context) (uuid.UUID, error)
 		GetSubscriptionOfToken(uuid.UUID, context.Context) (uint, error)
 		UseToken(uuid.UUID, context.Context) error
+		GetLastTokenTime(uint, context.Context) (time.Time, error)
+		InvalidateTokens(uint, context.Context) error
+	}
+
+	OutboxServer interface {
+		EnqueueConfirmation(uint, string, context.Context) (uint, error)
+		Dispatch(uint, context.Context) error
 	}
 
-	EmailServer interface {
-		SendConfirmationLetter(recipient string, confirmationUrl string) error
+	CityVerifier interface {
+		GetWeather(string, context.Context) (models.Weather, error)
+	}
+
+	UnitOfWork interface {
+		Execute(func(context.Context) error, context.Context) error
 	}
 )
 
-func NewSubscriptionBusinessService(subscriptionService SubscriptionDataServer, tokenService TokenServer, emailService EmailServer, baseUrl string) *SubscriptionControlService {
-	return &SubscriptionControlService{subscriptionService, tokenService, emailService, baseUrl}
+func NewSubscriptionBusinessService(subscriptionService SubscriptionDataServer, tokenService TokenServer, outboxService OutboxServer, cityVerifier CityVerifier, unitOfWork UnitOfWork, logger *slog.Logger) *SubscriptionControlService {
+	return &SubscriptionControlService{subscriptionService, tokenService, outboxService, cityVerifier, unitOfWork, logger}
 }
 
-func (s *SubscriptionControlService) Subscribe(subscription models.Subscription, ctx context.Context) error {
-	id, err := s.subscriptionDataService.AddSubscription(MapSubscription(subscription), ctx)
-	if err != nil {
+// Subscribe commits subscription and outbox record of its confirmation
+// in one transaction. Letter is dispatched only after commit; if it fails,
+// outbox relay retries it later. It reports whether subscription was
+// created, rather than letter of unconfirmed one sent again.
+func (s *SubscriptionControlService) Subscribe(subscription models.Subscription, ctx context.Context) (bool, error) {
+	var subscriptionId, messageId uint
+	var created bool
+
+	if err := s.verifyCity(subscription.City, ctx); err != nil {
+		return false, err
+	}
+
+	err := s.unitOfWork.Execute(func(ctx context.Context) error {
+		existing, err := s.subscriptionDataService.GetSubscriptionByEmail(subscription.Email, ctx)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			subscriptionId, err = s.subscriptionDataService.AddSubscription(MapSubscription(subscription), ctx)
+			if err != nil {
+				return err
+			}
+			created = true
+
+			messageId, err = s.enqueueConfirmation(subscriptionId, subscription.Email, ctx)
+			return err
+		}
+
+		subscriptionId = existing.ID
+		messageId, err = s.resendConfirmation(*existing, subscription, ctx)
 		return err
+	}, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	logger := s.logger.With(slog.Uint64("subscription_id", uint64(subscriptionId)))
+	logger.InfoContext(ctx, "confirmation requested", slog.String("city", subscription.City), slog.String("frequency", subscription.Frequency))
+
+	if err := s.outboxService.Dispatch(messageId, ctx); err != nil {
+		logger.WarnContext(ctx, "confirmation letter postponed", slog.Uint64("message_id", uint64(messageId)), slog.Any("error", err))
 	}
 
-	token, err := s.tokenService.CreateToken(id, ctx)
+	return created, nil
+}
+
+// verifyCity makes sure weather provider knows the city, otherwise
+// subscriber would never receive any letter.
+func (s *SubscriptionControlService) verifyCity(city string, ctx context.Context) error {
+	_, err := s.cityVerifier.GetWeather(city, ctx)
+	if apperrors.KindOf(err) == apperrors.NotFound {
+		return apperrors.Invalid("invalid subscription request", apperrors.FieldError{
+			Field:   "city",
+			Message: "city not found",
+		})
+	}
+
+	return err
+}
+
+// resendConfirmation issues fresh confirmation token for subscription which
+// was never confirmed, invalidating previously sent ones. Letters not sent
+// yet are superseded by the new one.
+func (s *SubscriptionControlService) resendConfirmation(existing models.Subscription, subscription models.Subscription, ctx context.Context) (uint, error) {
+	if existing.Confirmed {
+		return 0, ErrAlreadySubscribed
+	}
+
+	last, err := s.tokenService.GetLastTokenTime(existing.ID, ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	url := fmt.Sprintf("%s/api/confirm/%s", s.baseUrl, token)
+	if time.Since(last) < ConfirmationCooldown {
+		return 0, ErrConfirmationCooldown
+	}
 
-	if err := s.emailService.SendConfirmationLetter(subscription.Email, url); err != nil {
+	if err := s.tokenService.InvalidateTokens(existing.ID, ctx); err != nil {
+		return 0, err
+	}
+
+	existing.City = subscription.City
+	existing.Frequency = subscription.Frequency
+	if err := s.subscriptionDataService.UpdateSubscription(existing.ID, existing, ctx); err != nil {
+		return 0, err
+	}
+
+	return s.enqueueConfirmation(existing.ID, existing.Email, ctx)
+}
+
+// enqueueConfirmation records letter to be sent, its token is minted when
+// letter is dispatched.
+func (s *SubscriptionControlService) enqueueConfirmation(id uint, email string, ctx context.Context) (uint, error) {
+	return s.outboxService.EnqueueConfirmation(id, email, ctx)
+}
+
+// Confirm activates subscription token was issued for. Token of subscription
+// already confirmed is left unused.
+func (s *SubscriptionControlService) Confirm(token uuid.UUID, ctx context.Context) error {
+	var subscriberId uint
+
+	err := s.unitOfWork.Execute(func(ctx context.Context) error {
+		var err error
+		subscriberId, err = s.tokenService.GetSubscriptionOfToken(token, ctx)
+		if err != nil {
+			return err
+		}
+
+		subscription, err := s.subscriptionDataService.GetSubscriptionById(subscriberId, ctx)
+		if err != nil {
+			return err
+		}
+		if subscription.Confirmed {
+			return ErrAlreadyConfirmed
+		}
+
+		if err := s.tokenService.UseToken(token, ctx); err != nil {
+			return err
+		}
+
+		_, err = s.subscriptionDataService.ActivateSubscription(subscriberId, ctx)
+		return err
+	}, ctx)
+	if err != nil {
 		return err
 	}
 
+	s.logger.InfoContext(ctx, "subscription confirmed", slog.Uint64("subscription_id", uint64(subscriberId)))
 	return nil
 }
 
-func (s *SubscriptionControlService) Confirm(token uuid.UUID, ctx context.Context) error {
+func (s *SubscriptionControlService) Unsubscribe(token uuid.UUID, ctx context.Context) error {
 	subscriberId, err := s.tokenService.GetSubscriptionOfToken(token, ctx)
 	if err != nil {
 		return err
@@ -67,27 +210,67 @@ func (s *SubscriptionControlService) Confirm(token uuid.UUID, ctx context.Contex
 		return err
 	}
 
-	_, err = s.subscriptionDataService.ActivateSubscription(subscriberId, ctx)
-	if err != nil {
+	if err := s.subscriptionDataService.DeleteSubscription(subscriberId, ctx); err != nil {
 		return err
 	}
 
+	s.logger.InfoContext(ctx, "subscription cancelled", slog.Uint64("subscription_id", uint64(subscriberId)))
 	return nil
 }
 
-func (s *SubscriptionControlService) Unsubscribe(token uuid.UUID, ctx context.Context) error {
-	subscriberId, err := s.tokenService.GetSubscriptionOfToken(token, ctx)
+// Authorize checks that token was issued for subscription and is not expired.
+// Token is not consumed.
+func (s *SubscriptionControlService) Authorize(id uint, token uuid.UUID, ctx context.Context) error {
+	stored, err := s.tokenService.GetToken(token, ctx)
+	if apperrors.KindOf(err) == apperrors.NotFound {
+		return ErrForeignToken
+	}
 	if err != nil {
 		return err
 	}
 
-	if err := s.tokenService.UseToken(token, ctx); err != nil {
-		return err
+	if stored.SubscriptionID != id {
+		return ErrForeignToken
 	}
 
-	if err := s.subscriptionDataService.DeleteSubscription(subscriberId, ctx); err != nil {
+	if time.Now().After(stored.Expires) {
+		return ErrExpiredToken
+	}
+
+	return nil
+}
+
+// ConfirmSubscription confirms subscription with token issued for it.
+func (s *SubscriptionControlService) ConfirmSubscription(id uint, token uuid.UUID, ctx context.Context) error {
+	if err := s.Authorize(id, token, ctx); err != nil {
 		return err
 	}
 
-	return err
+	return s.Confirm(token, ctx)
+}
+
+// ChangeSubscription updates city and frequency of subscription, empty
+// values are left unchanged.
+func (s *SubscriptionControlService) ChangeSubscription(id uint, city string, frequency string, ctx context.Context) (models.Subscription, error) {
+	subscription, err := s.subscriptionDataService.GetSubscriptionById(id, ctx)
+	if err != nil {
+		return subscription, err
+	}
+
+	if city != "" && city != subscription.City {
+		if err := s.verifyCity(city, ctx); err != nil {
+			return subscription, err
+		}
+		subscription.City = city
+	}
+
+	if frequency != "" {
+		subscription.Frequency = frequency
+	}
+
+	if err := s.subscriptionDataService.UpdateSubscription(id, subscription, ctx); err != nil {
+		return subscription, err
+	}
+
+	return subscription, nil
 }
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Rabiann/weather-mailer/internal/apperrors"
+	"github.com/Rabiann/weather-mailer/internal/models"
+	"github.com/google/uuid"
+)
+
+type (
+	// memorySubscriptions holds at most one subscription, as tests need.
+	memorySubscriptions struct {
+		subscription *models.Subscription
+	}
+
+	// stubTokens remembers when last token was issued and whether tokens
+	// were invalidated or used.
+	stubTokens struct {
+		lastIssued  time.Time
+		invalidated bool
+		used        bool
+	}
+
+	recordingOutbox struct {
+		enqueued   []string
+		dispatched []uint
+	}
+
+	stubCities map[string]bool
+)
+
+func (m *memorySubscriptions) GetSubscriptionById(id uint, _ context.Context) (models.Subscription, error) {
+	if m.subscription == nil || m.subscription.ID != id {
+		return models.Subscription{}, apperrors.New(apperrors.NotFound, "subscription not found")
+	}
+	return *m.subscription, nil
+}
+
+func (m *memorySubscriptions) GetSubscriptionByEmail(email string, _ context.Context) (*models.Subscription, error) {
+	if m.subscription == nil || m.subscription.Email != email {
+		return nil, nil
+	}
+	subscription := *m.subscription
+	return &subscription, nil
+}
+
+func (m *memorySubscriptions) AddSubscription(subscription models.Subscription, _ context.Context) (uint, error) {
+	subscription.ID = 1
+	m.subscription = &subscription
+	return subscription.ID, nil
+}
+
+func (m *memorySubscriptions) ActivateSubscription(uint, context.Context) (string, error) {
+	m.subscription.Confirmed = true
+	return m.subscription.Email, nil
+}
+
+func (m *memorySubscriptions) UpdateSubscription(id uint, subscription models.Subscription, _ context.Context) error {
+	m.subscription = &subscription
+	return nil
+}
+
+func (m *memorySubscriptions) DeleteSubscription(uint, context.Context) error {
+	m.subscription = nil
+	return nil
+}
+
+func (s *stubTokens) GetToken(uuid.UUID, context.Context) (models.Token, error) {
+	return models.Token{}, apperrors.New(apperrors.NotFound, "token not found")
+}
+
+func (s *stubTokens) CreateToken(uint, context.Context) (uuid.UUID, error) {
+	s.lastIssued = time.Now()
+	return uuid.New(), nil
+}
+
+func (s *stubTokens) GetSubscriptionOfToken(uuid.UUID, context.Context) (uint, error) { return 1, nil }
+func (s *stubTokens) UseToken(uuid.UUID, context.Context) error {
+	s.used = true
+	return nil
+}
+
+func (s *stubTokens) GetLastTokenTime(uint, context.Context) (time.Time, error) {
+	return s.lastIssued, nil
+}
+
+func (s *stubTokens) InvalidateTokens(uint, context.Context) error {
+	s.invalidated = true
+	return nil
+}
+
+func (r *recordingOutbox) EnqueueConfirmation(subscriptionId uint, recipient string, _ context.Context) (uint, error) {
+	r.enqueued = append(r.enqueued, recipient)
+	return uint(len(r.enqueued)), nil
+}
+
+func (r *recordingOutbox) Dispatch(id uint, _ context.Context) error {
+	r.dispatched = append(r.dispatched, id)
+	return nil
+}
+
+func (s stubCities) GetWeather(city string, _ context.Context) (models.Weather, error) {
+	if !s[city] {
+		return models.Weather{}, apperrors.New(apperrors.NotFound, "city not found")
+	}
+	return models.Weather{}, nil
+}
+
+func newTestSubscriptions(existing *models.Subscription, lastToken time.Time) (*SubscriptionControlService, *memorySubscriptions, *stubTokens, *recordingOutbox) {
+	subscriptions := &memorySubscriptions{subscription: existing}
+	tokens := &stubTokens{lastIssued: lastToken}
+	outbox := &recordingOutbox{}
+	service := NewSubscriptionBusinessService(subscriptions, tokens, outbox, stubCities{"Kyiv": true, "Lviv": true}, directUnitOfWork{}, testLogger)
+	return service, subscriptions, tokens, outbox
+}
+
+func request(city string) models.Subscription {
+	return models.Subscription{Email: "user@example.com", City: city, Frequency: models.FrequencyDaily}
+}
+
+func TestSubscribeEnqueuesConfirmation(t *testing.T) {
+	service, subscriptions, _, outbox := newTestSubscriptions(nil, time.Time{})
+
+	created, err := service.Subscribe(request("Kyiv"), context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subscriptions.subscription == nil || !created {
+		t.Fatal("subscription was not added")
+	}
+	if len(outbox.enqueued) != 1 || len(outbox.dispatched) != 1 {
+		t.Errorf("%d letters enqueued and %d dispatched, want 1 and 1", len(outbox.enqueued), len(outbox.dispatched))
+	}
+}
+
+func TestSubscribeToUnknownCityIsInvalid(t *testing.T) {
+	service, subscriptions, _, outbox := newTestSubscriptions(nil, time.Time{})
+
+	_, err := service.Subscribe(request("Atlantis"), context.Background())
+	if apperrors.KindOf(err) != apperrors.Validation {
+		t.Fatalf("got %v, want validation error", err)
+	}
+	if subscriptions.subscription != nil || len(outbox.enqueued) != 0 {
+		t.Error("subscription to unknown city was stored")
+	}
+}
+
+func TestResubscribeResendsConfirmationAfterCooldown(t *testing.T) {
+	existing := &models.Subscription{ID: 1, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyHourly}
+	service, subscriptions, tokens, outbox := newTestSubscriptions(existing, time.Now().Add(-ConfirmationCooldown-time.Second))
+
+	created, err := service.Subscribe(request("Lviv"), context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Error("existing subscription is reported created")
+	}
+
+	if !tokens.invalidated {
+		t.Error("tokens sent before were not invalidated")
+	}
+	if len(outbox.enqueued) != 1 || len(outbox.dispatched) != 1 {
+		t.Fatalf("%d letters enqueued and %d dispatched, want 1 and 1", len(outbox.enqueued), len(outbox.dispatched))
+	}
+	// the latest request wins, as it is what confirmation would activate
+	if subscriptions.subscription.City != "Lviv" || subscriptions.subscription.Frequency != models.FrequencyDaily {
+		t.Errorf("subscription was not updated: %+v", subscriptions.subscription)
+	}
+}
+
+func TestResubscribeWithinCooldownIsThrottled(t *testing.T) {
+	existing := &models.Subscription{ID: 1, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyHourly}
+	service, subscriptions, tokens, outbox := newTestSubscriptions(existing, time.Now().Add(-time.Minute))
+
+	_, err := service.Subscribe(request("Lviv"), context.Background())
+	if !errors.Is(err, ErrConfirmationCooldown) {
+		t.Fatalf("got %v, want %v", err, ErrConfirmationCooldown)
+	}
+	if apperrors.KindOf(err) != apperrors.Throttled {
+		t.Errorf("cooldown is %v error, want throttled", apperrors.KindOf(err))
+	}
+
+	if tokens.invalidated || len(outbox.enqueued) != 0 || len(outbox.dispatched) != 0 {
+		t.Error("letter was resent within cooldown")
+	}
+	if subscriptions.subscription.City != "Kyiv" {
+		t.Error("subscription was changed within cooldown")
+	}
+}
+
+func TestResubscribeConfirmedIsConflict(t *testing.T) {
+	existing := &models.Subscription{ID: 1, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyDaily, Confirmed: true}
+	service, _, tokens, outbox := newTestSubscriptions(existing, time.Time{})
+
+	_, err := service.Subscribe(request("Kyiv"), context.Background())
+	if !errors.Is(err, ErrAlreadySubscribed) {
+		t.Fatalf("got %v, want %v", err, ErrAlreadySubscribed)
+	}
+	if tokens.invalidated || len(outbox.enqueued) != 0 {
+		t.Error("confirmed subscription was sent confirmation again")
+	}
+}
+
+func TestConfirmUsesToken(t *testing.T) {
+	existing := &models.Subscription{ID: 1, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyDaily}
+	service, subscriptions, tokens, _ := newTestSubscriptions(existing, time.Time{})
+
+	if err := service.Confirm(uuid.New(), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !subscriptions.subscription.Confirmed || !tokens.used {
+		t.Errorf("subscription confirmed: %t, token used: %t", subscriptions.subscription.Confirmed, tokens.used)
+	}
+}
+
+// Token sent to subscriber stays usable, for example to unsubscribe, when
+// subscription was confirmed already.
+func TestConfirmConfirmedKeepsToken(t *testing.T) {
+	existing := &models.Subscription{ID: 1, Email: "user@example.com", City: "Kyiv", Frequency: models.FrequencyDaily, Confirmed: true}
+	service, _, tokens, _ := newTestSubscriptions(existing, time.Time{})
+
+	err := service.Confirm(uuid.New(), context.Background())
+	if !errors.Is(err, ErrAlreadyConfirmed) {
+		t.Fatalf("got %v, want %v", err, ErrAlreadyConfirmed)
+	}
+	if tokens.used {
+		t.Error("token was used for confirmed subscription")
+	}
+}
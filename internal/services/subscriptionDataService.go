@@ -12,6 +12,8 @@ type (
 	}
 
 	SubscriptionRepository interface {
+		GetSubscriptionById(id uint, ctx context.Context) (models.Subscription, error)
+		GetSubscriptionByEmail(email string, ctx context.Context) (*models.Subscription, error)
 		AddSubscription(subscription models.Subscription, ctx context.Context) (uint, error)
 		ActivateSubscription(id uint, ctx context.Context) (string, error)
 		GetActiveSubscriptions(per string, ctx context.Context) ([]models.Subscription, error)
@@ -34,6 +36,14 @@ func MapSubscription(subscriptionRequest models.Subscription) models.Subscriptio
 	}
 }
 
+func (s SubscriptionDataService) GetSubscriptionById(id uint, ctx context.Context) (models.Subscription, error) {
+	return s.subscriptionRepository.GetSubscriptionById(id, ctx)
+}
+
+func (s SubscriptionDataService) GetSubscriptionByEmail(email string, ctx context.Context) (*models.Subscription, error) {
+	return s.subscriptionRepository.GetSubscriptionByEmail(email, ctx)
+}
+
 func (s *SubscriptionDataService) AddSubscription(subscription models.Subscription, ctx context.Context) (uint, error) {
 	return s.subscriptionRepository.AddSubscription(subscription, ctx)
 }
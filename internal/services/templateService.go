@@ -1,13 +1,21 @@
 package services
 
 import (
-	"os"
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
 	"strings"
 )
 
+// sampleUrl is link letters are rendered with when templates are checked.
+const sampleUrl = "https://example.com/check-link"
+
 type (
+	// Template is HTML letter template, values given to it are escaped
+	// according to where they are placed.
 	Template struct {
-		text string
+		html *template.Template
 	}
 
 	ConfirmationTemplate struct {
@@ -17,47 +25,90 @@ type (
 	WeatherTemplate struct {
 		template *Template
 	}
+
+	// ConfirmationLetter is data of confirmation letter template.
+	ConfirmationLetter struct {
+		ConfirmationUrl string
+	}
+
+	// WeatherLetter is data of weather letter template.
+	WeatherLetter struct {
+		City string
+		// Period is `daily` or `hourly`.
+		Period         string
+		Temperature    float64
+		Humidity       float64
+		Description    string
+		UnsubscribeUrl string
+	}
 )
 
-func NewTemplate(filepath string) (*Template, error) {
-	text, err := os.ReadFile(filepath)
+func NewTemplate(path string) (*Template, error) {
+	html, err := template.New(filepath.Base(path)).Option("missingkey=error").ParseFiles(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var template Template
+	return &Template{html: html}, nil
+}
 
-	template.text = string(text)
-	return &template, nil
+// render executes template with data, so that half written letter is
+// never returned.
+func (t *Template) render(data any) (string, error) {
+	var letter bytes.Buffer
+	if err := t.html.Execute(&letter, data); err != nil {
+		return "", err
+	}
+
+	return letter.String(), nil
 }
 
-func NewConfirmationTemplate(filepath string) (*ConfirmationTemplate, error) {
-	template, err := NewTemplate(filepath)
+// checkLink renders template with sample data and fails when letter lacks
+// link, which also catches fields template names but data lacks.
+func (t *Template) checkLink(path string, data any) error {
+	letter, err := t.render(data)
 	if err != nil {
+		return fmt.Errorf("template `%s`: %w", path, err)
+	}
+
+	if !strings.Contains(letter, sampleUrl) {
+		return fmt.Errorf("template `%s` does not show link of letter", path)
+	}
+
+	return nil
+}
+
+func NewConfirmationTemplate(path string) (*ConfirmationTemplate, error) {
+	letterTemplate, err := NewTemplate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := letterTemplate.checkLink(path, ConfirmationLetter{ConfirmationUrl: sampleUrl}); err != nil {
 		return nil, err
 	}
 
-	return &ConfirmationTemplate{template: template}, nil
+	return &ConfirmationTemplate{template: letterTemplate}, nil
 }
 
-func NewWeatherTemplate(filepath string) (*WeatherTemplate, error) {
-	template, err := NewTemplate(filepath)
+func NewWeatherTemplate(path string) (*WeatherTemplate, error) {
+	letterTemplate, err := NewTemplate(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return &WeatherTemplate{template: template}, nil
+	// letters without unsubscribe link can not be sent lawfully
+	if err := letterTemplate.checkLink(path, WeatherLetter{UnsubscribeUrl: sampleUrl}); err != nil {
+		return nil, err
+	}
+
+	return &WeatherTemplate{template: letterTemplate}, nil
 }
 
-func (ct *ConfirmationTemplate) buildConfirmationLetter(email string) string {
-	return strings.Replace(ct.template.text, "{}", email, 3)
+func (ct *ConfirmationTemplate) buildConfirmationLetter(letter ConfirmationLetter) (string, error) {
+	return ct.template.render(letter)
 }
 
-func (wt *WeatherTemplate) buildWeatherLetter(city string, temp string, humid string, description string, unsubscribe string) string {
-	let := strings.Replace(wt.template.text, "{City}", city, 1)
-	let = strings.Replace(let, "{Temperature}", temp, 1)
-	let = strings.Replace(let, "{Humidity}", humid, 1)
-	let = strings.Replace(let, "{UnsubscribeLink}", unsubscribe, 1)
-	let = strings.Replace(let, "{Description}", description, 1)
-	return let
+func (wt *WeatherTemplate) buildWeatherLetter(letter WeatherLetter) (string, error) {
+	return wt.template.render(letter)
 }
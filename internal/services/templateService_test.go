@@ -0,0 +1,147 @@
+package services
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with rendered letters")
+
+// templatesDir is where letter templates are relative to this package.
+const templatesDir = "../../templates"
+
+func assertGolden(t *testing.T, name string, letter string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(letter), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden file: %v, run `go test ./internal/services -update` to create it", err)
+	}
+
+	if letter != string(want) {
+		t.Errorf("letter differs from %s, run `go test ./internal/services -update` if change is intended\ngot:\n%s", path, letter)
+	}
+}
+
+func TestConfirmationLetter(t *testing.T) {
+	confirmationTemplate, err := NewConfirmationTemplate(filepath.Join(templatesDir, "confirmationMail.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		letter ConfirmationLetter
+	}{
+		{
+			name:   "confirmation",
+			letter: ConfirmationLetter{ConfirmationUrl: "http://localhost:8000/api/confirm/6f1c2b9e-3d4a-4c1e-9b7a-0e5d2f8a1c3b"},
+		},
+		{
+			name:   "confirmation_escaped",
+			letter: ConfirmationLetter{ConfirmationUrl: `http://localhost:8000/api/confirm/x?a=1&b="2"<script>alert(1)</script>`},
+		},
+		{
+			name:   "confirmation_unsafe_scheme",
+			letter: ConfirmationLetter{ConfirmationUrl: "javascript:alert(1)"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			letter, err := confirmationTemplate.buildConfirmationLetter(test.letter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, test.name, letter)
+		})
+	}
+}
+
+func TestWeatherLetter(t *testing.T) {
+	weatherTemplate, err := NewWeatherTemplate(filepath.Join(templatesDir, "weatherMail.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		letter WeatherLetter
+	}{
+		{
+			name: "weather",
+			letter: WeatherLetter{
+				City:           "Kyiv",
+				Period:         "daily",
+				Temperature:    21.46,
+				Humidity:       40,
+				Description:    "Partly cloudy",
+				UnsubscribeUrl: "http://localhost:8000/api/unsubscribe/6f1c2b9e-3d4a-4c1e-9b7a-0e5d2f8a1c3b",
+			},
+		},
+		{
+			name: "weather_escaped",
+			letter: WeatherLetter{
+				City:           `<script>alert("city")</script>`,
+				Period:         "hourly",
+				Temperature:    -3.05,
+				Humidity:       99.9,
+				Description:    `<b onclick='steal()'>"Sunny" & warm</b>`,
+				UnsubscribeUrl: "http://localhost:8000/api/unsubscribe/6f1c2b9e-3d4a-4c1e-9b7a-0e5d2f8a1c3b",
+			},
+		},
+		{
+			name: "weather_without_description",
+			letter: WeatherLetter{
+				City:           "Lviv",
+				Period:         "daily",
+				Temperature:    0,
+				Humidity:       0,
+				UnsubscribeUrl: "http://localhost:8000/api/unsubscribe/00000000-0000-0000-0000-000000000000",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			letter, err := weatherTemplate.buildWeatherLetter(test.letter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, test.name, letter)
+		})
+	}
+}
+
+func TestTemplateWithoutLinkIsRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weatherMail.tmpl")
+	if err := os.WriteFile(path, []byte("<p>Weather in {{.City}}</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWeatherTemplate(path); err == nil {
+		t.Fatal("template without unsubscribe link was accepted")
+	}
+}
+
+func TestTemplateWithUnknownFieldIsRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "confirmationMail.tmpl")
+	if err := os.WriteFile(path, []byte(`<a href="{{.ConfirmationUrl}}">{{.Email}}</a>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewConfirmationTemplate(path); err == nil {
+		t.Fatal("template naming unknown field was accepted")
+	}
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"time"
 
+	"github.com/Rabiann/weather-mailer/internal/models"
 	"github.com/google/uuid"
 )
 
@@ -13,8 +15,11 @@ type (
 
 	TokenRepository interface {
 		CreateToken(subscriptionId uint, ctx context.Context) (uuid.UUID, error)
+		GetToken(id uuid.UUID, ctx context.Context) (models.Token, error)
 		GetSubscriptionOfToken(id uuid.UUID, ctx context.Context) (uint, error)
 		UseToken(id uuid.UUID, ctx context.Context) error
+		GetLastTokenTime(subscriptionId uint, ctx context.Context) (time.Time, error)
+		DeleteTokensOfSubscription(subscriptionId uint, ctx context.Context) error
 	}
 )
 
@@ -26,6 +31,10 @@ func (t TokenService) CreateToken(subscriptionId uint, ctx context.Context) (uui
 	return t.tokenRepository.CreateToken(subscriptionId, ctx)
 }
 
+func (t TokenService) GetToken(id uuid.UUID, ctx context.Context) (models.Token, error) {
+	return t.tokenRepository.GetToken(id, ctx)
+}
+
 func (t TokenService) GetSubscriptionOfToken(id uuid.UUID, ctx context.Context) (uint, error) {
 	return t.tokenRepository.GetSubscriptionOfToken(id, ctx)
 }
@@ -33,3 +42,11 @@ func (t TokenService) GetSubscriptionOfToken(id uuid.UUID, ctx context.Context)
 func (t TokenService) UseToken(id uuid.UUID, ctx context.Context) error {
 	return t.tokenRepository.UseToken(id, ctx)
 }
+
+func (t TokenService) GetLastTokenTime(subscriptionId uint, ctx context.Context) (time.Time, error) {
+	return t.tokenRepository.GetLastTokenTime(subscriptionId, ctx)
+}
+
+func (t TokenService) InvalidateTokens(subscriptionId uint, ctx context.Context) error {
+	return t.tokenRepository.DeleteTokensOfSubscription(subscriptionId, ctx)
+}
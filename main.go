@@ -1,13 +1,35 @@
 package main
 
 import (
+	"os"
+
 	"github.com/Rabiann/weather-mailer/internal/cmd"
 )
 
 func main() {
 	var app cmd.App
 
-	if err := app.Run(); err != nil {
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "serve":
+		err = app.Serve(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "worker":
+		err = app.Worker(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "notify-once":
+		err = app.NotifyOnce(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "send-test-email":
+		err = app.SendTestEmail(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "migrate":
+		err = app.Migrate(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "apikey":
+		err = app.ApiKey(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "config":
+		err = app.Config(os.Args[2:])
+	default:
+		err = app.Run(os.Args[1:])
+	}
+
+	if err != nil {
 		panic(err)
 	}
 }
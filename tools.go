@@ -0,0 +1,7 @@
+//go:build tools
+
+package main
+
+import (
+	_ "github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen"
+)